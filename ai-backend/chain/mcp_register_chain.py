@@ -37,6 +37,17 @@ _ACTOR_CN: contextvars.ContextVar[Optional[str]] = contextvars.ContextVar(
 )
 
 
+def _authorization_header() -> Optional[str]:
+    """Forwarded member JWT first; otherwise the service-account token from GO_API_TOKEN."""
+    auth = _AUTHORIZATION_HEADER.get()
+    if auth:
+        return auth
+    token = (os.getenv("GO_API_TOKEN") or "").strip()
+    if token:
+        return f"Bearer {token}"
+    return None
+
+
 def _mcp_admin_cns() -> set[str]:
     raw = (os.getenv("MCP_ADMIN_CNS") or "").strip()
     if not raw:
@@ -227,11 +238,15 @@ class UpdateMemberInput(BaseModel):
 	position: Optional[str] = None
 	year: Optional[str] = None
 	direction: Optional[str] = None
-	status: Optional[str] = None
-	is_member: Optional[bool] = None
 	remark: Optional[str] = None
 
 
+class ChangeStatusInput(BaseModel):
+	cn: str = Field(..., description="成员 cn")
+	status: str = Field(..., description="目标状态：active 在役、on_leave 暂离、retired 已退居幕后、graduated 已毕业、alumni 校友")
+	reason: str = Field(..., description="变更原因（必填）")
+
+
 def _generate_password(length: int = 12) -> str:
     alphabet = string.ascii_letters + string.digits
     # ensure at least one letter and one digit
@@ -247,7 +262,7 @@ def _default_template() -> dict[str, str]:
         "position": "成员",
         "year": "",
         "direction": "",
-        "status": "在役",
+        "status": "active",
         "remark": "",
     }
 
@@ -307,7 +322,7 @@ def register_member(
     url = f"{go_base.rstrip('/')}/api/mcp/register"
 
     headers = {}
-    auth = _AUTHORIZATION_HEADER.get()
+    auth = _authorization_header()
     if auth:
         headers["Authorization"] = auth
 
@@ -356,7 +371,7 @@ def get_member(cn: str) -> str:
     cn_enc = urllib.parse.quote(cn, safe="")
     url = f"{go_base.rstrip('/')}/api/mcp/club_members/{cn_enc}"
     headers = {}
-    auth = _AUTHORIZATION_HEADER.get()
+    auth = _authorization_header()
     if auth:
         headers["Authorization"] = auth
 
@@ -395,11 +410,12 @@ def update_member(
     position: Optional[str] = None,
     year: Optional[str] = None,
     direction: Optional[str] = None,
-    status: Optional[str] = None,
-    is_member: Optional[bool] = None,
     remark: Optional[str] = None,
 ) -> str:
-    """Update own member info (excluding password): PUT /api/mcp/club_members/{cn}."""
+    """Update own member info (excluding password, status and membership): PUT /api/mcp/club_members/{cn}.
+
+    Status changes go through change_member_status; membership is granted by approving the membership application.
+    """
 
     actor_cn = _ACTOR_CN.get()
     if actor_cn and not _is_admin(actor_cn):
@@ -408,7 +424,7 @@ def update_member(
     cn_enc = urllib.parse.quote(cn, safe="")
     url = f"{go_base.rstrip('/')}/api/mcp/club_members/{cn_enc}"
     headers = {"Content-Type": "application/json"}
-    auth = _AUTHORIZATION_HEADER.get()
+    auth = _authorization_header()
     if auth:
         headers["Authorization"] = auth
 
@@ -421,10 +437,6 @@ def update_member(
         payload["year"] = year
     if direction is not None:
         payload["direction"] = direction
-    if status is not None:
-        payload["status"] = status
-    if is_member is not None:
-        payload["is_member"] = is_member
     if remark is not None:
         payload["remark"] = remark
 
@@ -457,6 +469,52 @@ def update_member(
         )
 
 
+@tool("change_member_status", args_schema=ChangeStatusInput)
+def change_member_status(cn: str, status: str, reason: str) -> str:
+    """Change member status along the lifecycle: POST /api/mcp/club_members/{cn}/status."""
+
+    actor_cn = _ACTOR_CN.get()
+    if actor_cn and not _is_admin(actor_cn):
+        cn = actor_cn
+    go_base = os.getenv("GO_API_BASE", "http://127.0.0.1:7777")
+    cn_enc = urllib.parse.quote(cn, safe="")
+    url = f"{go_base.rstrip('/')}/api/mcp/club_members/{cn_enc}/status"
+    headers = {"Content-Type": "application/json"}
+    auth = _authorization_header()
+    if auth:
+        headers["Authorization"] = auth
+
+    payload = {"status": _clean_str(status), "reason": _clean_str(reason)}
+
+    try:
+        status_code, resp_headers, raw = _http_request(
+            method="POST", url=url, body=payload, headers=headers, timeout=15
+        )
+        content_type = resp_headers.get("content-type", "")
+        data: Any
+        if "application/json" in content_type.lower():
+            try:
+                data = json.loads(raw) if raw else {}
+            except Exception:
+                data = {"raw": raw}
+        else:
+            data = {"raw": raw}
+        result = {
+            "ok": status_code == 200,
+            "status_code": status_code,
+            "url": url,
+            "request": payload,
+            "response": data,
+            "cn": cn,
+        }
+        return json.dumps(result, ensure_ascii=False)
+    except Exception as e:
+        return json.dumps(
+            {"ok": False, "error": f"{type(e).__name__}: {str(e)}", "url": url, "cn": cn},
+            ensure_ascii=False,
+        )
+
+
 @tool("delete_member", args_schema=MemberCNInput)
 def delete_member(cn: str) -> str:
     """Delete own member row: DELETE /api/mcp/club_members/{cn}."""
@@ -468,7 +526,7 @@ def delete_member(cn: str) -> str:
     cn_enc = urllib.parse.quote(cn, safe="")
     url = f"{go_base.rstrip('/')}/api/mcp/club_members/{cn_enc}"
     headers = {}
-    auth = _AUTHORIZATION_HEADER.get()
+    auth = _authorization_header()
     if auth:
         headers["Authorization"] = auth
     try:
@@ -560,7 +618,7 @@ async def stream_mcp_register_reply(
         if actor_cn and actor_cn.strip():
             cn_token = _ACTOR_CN.set(actor_cn.strip())
 
-        tools = [register_member, get_member, update_member, delete_member]
+        tools = [register_member, get_member, update_member, change_member_status, delete_member]
 
         planner = ChatOpenAI(
             model=model_name,
@@ -732,6 +790,9 @@ async def stream_mcp_register_reply(
                     elif name == "update_member":
                         result = update_member.invoke(args)
                         messages.append(ToolMessage(content=result, tool_call_id=call_id))
+                    elif name == "change_member_status":
+                        result = change_member_status.invoke(args)
+                        messages.append(ToolMessage(content=result, tool_call_id=call_id))
                     elif name == "delete_member":
                         result = delete_member.invoke(args)
                         messages.append(ToolMessage(content=result, tool_call_id=call_id))
@@ -1,4 +1,5 @@
 import os
+import json
 import shutil
 import glob
 from typing import List, Dict, Any, Optional
@@ -19,6 +20,24 @@ from sklearn.metrics.pairwise import cosine_similarity
 load_dotenv(dotenv_path=os.path.join(os.path.dirname(__file__), ".env"), override=True)
 load_dotenv(dotenv_path=os.path.join(os.path.dirname(__file__), "..", ".env"), override=False)
 
+# 成员状态代码到中文名称的映射，与 Go 后端 models.MemberStatusLabel 保持一致
+MEMBER_STATUS_LABELS = {
+    "applicant": "申请中",
+    "active": "在役",
+    "on_leave": "暂离",
+    "retired": "已退居幕后",
+    "graduated": "已毕业",
+    "alumni": "校友",
+}
+
+# 成员字段的默认可见范围，与 Go 后端 models.DefaultVisibility 保持一致；知识库只收录 public 字段
+DEFAULT_FIELD_VISIBILITY = {
+    "sex": "members",
+    "year": "public",
+    "direction": "public",
+    "remark": "members",
+}
+
 class RAGService:
     def __init__(self):
         self.api_key = os.getenv("DEEPSEEK_API_KEY")
@@ -261,8 +280,22 @@ class RAGService:
             # Connect to SQLite
             engine = create_engine(f"sqlite:///{self.db_path}")
             with engine.connect() as conn:
-                result = conn.execute(text("SELECT * FROM club_members"))
+                result = conn.execute(text("SELECT * FROM club_members WHERE deleted_at IS NULL"))
                 members = result.mappings().all()
+                # 成员的隐私设置（旧数据库可能还没有这张表）
+                privacy_by_cn = {}
+                has_privacy = conn.execute(text(
+                    "SELECT name FROM sqlite_master WHERE type='table' AND name='member_privacies'"
+                )).first()
+                if has_privacy:
+                    rows = conn.execute(text(
+                        "SELECT cn, settings FROM member_privacies WHERE deleted_at IS NULL"
+                    )).mappings().all()
+                    for row in rows:
+                        try:
+                            privacy_by_cn[row["cn"]] = json.loads(row["settings"] or "{}")
+                        except ValueError:
+                            privacy_by_cn[row["cn"]] = {}
                 
             # Generate Markdown
             content = "---\n"
@@ -295,17 +328,27 @@ class RAGService:
                 direction = get_field(member, ['direction', 'Direction'])
                 position = get_field(member, ['position', 'Position'])
                 status = get_field(member, ['status', 'Status'])
+                # 状态以标准代码存储，写入知识库时换成中文名称
+                status = MEMBER_STATUS_LABELS.get(status, status)
                 remark = get_field(member, ['remark', 'Remark'])
 
                 # If cn was not found above (loop logic fix) - re-assigning for consistency
                 # Actually mapping keys might be lowercase 'cn', 'sex', etc.
                 
-                content += f"**性别**: {sex}\n\n"
-                content += f"**年级**: {year}\n\n"
-                content += f"**方向**: {direction}\n\n"
+                settings = privacy_by_cn.get(cn, {})
+
+                def is_public(field):
+                    return settings.get(field, DEFAULT_FIELD_VISIBILITY[field]) == "public"
+
+                if is_public("sex"):
+                    content += f"**性别**: {sex}\n\n"
+                if is_public("year"):
+                    content += f"**年级**: {year}\n\n"
+                if is_public("direction"):
+                    content += f"**方向**: {direction}\n\n"
                 content += f"**职位**: {position}\n\n"
                 content += f"**状态**: {status}\n\n"
-                if remark and remark != "未知":
+                if remark and remark != "未知" and is_public("remark"):
                     content += f"**备注**: {remark}\n\n"
                 content += "---\n"
 
@@ -39,7 +39,36 @@ func InitDB() {
 	fmt.Println("Database connected successfully")
 
 	// 自动迁移模型
-	err = DB.AutoMigrate(&models.ClubMember{}, &models.Activity{}, &models.MemberProfile{}, &models.MemoryCode{}, &models.Document{}, &models.DocumentChunk{})
+	err = DB.AutoMigrate(
+		&models.ClubMember{},
+		&models.Activity{},
+		&models.MemberProfile{},
+		&models.MemoryCode{},
+		&models.Document{},
+		&models.DocumentChunk{},
+		&models.SigningKey{},
+		&models.RefreshToken{},
+		&models.Role{},
+		&models.Permission{},
+		&models.MemberRole{},
+		&models.AuditLog{},
+		&models.PasswordResetToken{},
+		&models.AuthAttempt{},
+		&models.InvitationCode{},
+		&models.MembershipApplication{},
+		&models.ServiceAccount{},
+		&models.AccessToken{},
+		&models.ExternalIdentity{},
+		&models.OIDCLoginState{},
+		&models.OIDCLoginTicket{},
+		&models.MemberAlias{},
+		&models.Tenure{},
+		&models.StatusChange{},
+		&models.Rollover{},
+		&models.OrgPosition{},
+		&models.VocabTerm{},
+		&models.MemberPrivacy{},
+	)
 	if err != nil {
 		fmt.Printf("Migration error: %v\n", err)
 		panic("failed to migrate database")
@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// accessTokenPrefix 访问令牌的固定前缀，VerifyToken 据此区分访问令牌与 JWT
+const accessTokenPrefix = "scvg_pat_"
+
+// 访问令牌归属
+const (
+	tokenOwnerMember  = "member"
+	tokenOwnerService = "service"
+)
+
+// serviceAccountRole 服务账号在 token 上下文中的角色名
+const serviceAccountRole = "service"
+
+// 访问令牌作用域，决定令牌可以调用哪些接口
+const (
+	ScopeMembersRead     = "members:read"     // 查询成员信息
+	ScopeMembersWrite    = "members:write"    // 注册、修改、删除成员
+	ScopeProfileWrite    = "profile:write"    // 编辑个人主页
+	ScopeActivitiesWrite = "activities:write" // 发布活动
+)
+
+var scopeDescriptions = map[string]string{
+	ScopeMembersRead:     "查询成员信息",
+	ScopeMembersWrite:    "注册、修改、删除成员",
+	ScopeProfileWrite:    "编辑个人主页",
+	ScopeActivitiesWrite: "发布活动",
+}
+
+// scopePermissions 作用域对应的命名权限；个人令牌的权限为成员权限与之的交集
+var scopePermissions = map[string]string{
+	ScopeMembersWrite:    PermMembersWrite,
+	ScopeProfileWrite:    PermProfileWrite,
+	ScopeActivitiesWrite: PermActivitiesWrite,
+}
+
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+var (
+	errAccessTokenInvalid = errors.New("无效的访问令牌")
+	errAccessTokenScope   = errors.New("访问令牌无权调用该接口")
+)
+
+// serviceAccountCN 服务账号在 user_cn 与审计日志中的标识，与成员 CN 区分
+func serviceAccountCN(name string) string { return "svc:" + name }
+
+// RequireScope 声明接口允许访问令牌调用所需的作用域；未声明的接口只接受登录会话
+func RequireScope(scope string) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("required_scope", scope)
+			return next(c)
+		}
+	}
+}
+
+// normalizeScopes 校验并去重作用域
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if _, ok := scopeDescriptions[s]; !ok {
+			return nil, errors.New("未知的作用域: " + s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("至少需要一个作用域")
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func scopedPermissions(scopes []string) map[string]bool {
+	perms := map[string]bool{}
+	for _, s := range scopes {
+		if p, ok := scopePermissions[s]; ok {
+			perms[p] = true
+		}
+	}
+	return perms
+}
+
+// authenticateAccessToken 校验访问令牌，并将令牌身份写入上下文（与 JWT 使用相同的键）
+func authenticateAccessToken(c echo.Context, raw string) error {
+	var tok models.AccessToken
+	if err := config.DB.Where("token_hash = ?", hashToken(raw)).First(&tok).Error; err != nil {
+		return errAccessTokenInvalid
+	}
+	if tok.RevokedAt != nil || (tok.ExpiresAt != nil && time.Now().After(*tok.ExpiresAt)) {
+		return errAccessTokenInvalid
+	}
+
+	scopes := strings.Fields(tok.Scopes)
+	required, _ := c.Get("required_scope").(string)
+	if required == "" || !containsString(scopes, required) {
+		return errAccessTokenScope
+	}
+	allowed := scopedPermissions(scopes)
+
+	var cn string
+	var isMember bool
+	var roles, perms []string
+	switch tok.OwnerType {
+	case tokenOwnerMember:
+		var member models.ClubMember
+		if err := config.DB.Where("cn = ?", tok.Owner).First(&member).Error; err != nil {
+			return errAccessTokenInvalid
+		}
+		memberRoles, memberPerms, err := memberAccess(member)
+		if err != nil {
+			return errAccessTokenInvalid
+		}
+		for _, p := range memberPerms {
+			if allowed[p] {
+				perms = append(perms, p)
+			}
+		}
+		cn, isMember, roles = member.CN, member.IsMember, memberRoles
+	case tokenOwnerService:
+		var account models.ServiceAccount
+		if err := config.DB.Where("name = ? AND disabled_at IS NULL", tok.Owner).First(&account).Error; err != nil {
+			return errAccessTokenInvalid
+		}
+		for p := range allowed {
+			perms = append(perms, p)
+		}
+		sort.Strings(perms)
+		cn, isMember, roles = serviceAccountCN(account.Name), true, []string{serviceAccountRole}
+	default:
+		return errAccessTokenInvalid
+	}
+
+	now := time.Now()
+	config.DB.Model(&tok).UpdateColumns(map[string]interface{}{
+		"last_used_at": &now,
+		"last_used_ip": c.RealIP(),
+	})
+
+	c.Set("user_cn", cn)
+	c.Set("is_member", isMember)
+	c.Set("session_id", "")
+	c.Set("roles", roles)
+	c.Set("permissions", perms)
+	c.Set("mfa", tok.MFA)
+	c.Set("access_token_id", tok.ID)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func accessTokenResponse(tok models.AccessToken) echo.Map {
+	return echo.Map{
+		"id":           tok.ID,
+		"name":         tok.Name,
+		"owner_type":   tok.OwnerType,
+		"owner":        tok.Owner,
+		"hint":         tok.Hint,
+		"scopes":       strings.Fields(tok.Scopes),
+		"expires_at":   tok.ExpiresAt,
+		"last_used_at": tok.LastUsedAt,
+		"last_used_ip": tok.LastUsedIP,
+		"revoked_at":   tok.RevokedAt,
+		"created_by":   tok.CreatedBy,
+		"created_at":   tok.CreatedAt,
+	}
+}
+
+// createAccessToken 为指定归属签发令牌，明文只在响应中返回一次
+func createAccessToken(c echo.Context, ownerType, owner string) error {
+	type CreateRequest struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expires_in"` // 如 720h，为空表示不过期
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req CreateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "令牌名称不能为空"})
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "有效期格式错误"})
+		}
+		t := time.Now().Add(d)
+		expiresAt = &t
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成令牌失败"})
+	}
+	raw := accessTokenPrefix + secret
+	// 令牌继承创建时会话的两步验证状态，供管理员强制两步验证时判断
+	mfa, _ := c.Get("mfa").(bool)
+
+	tok := models.AccessToken{
+		Name:      strings.TrimSpace(req.Name),
+		OwnerType: ownerType,
+		Owner:     owner,
+		TokenHash: hashToken(raw),
+		Hint:      raw[:len(accessTokenPrefix)+4],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+		CreatedBy: actorCN,
+		MFA:       mfa,
+	}
+	if err := config.DB.Create(&tok).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成令牌失败"})
+	}
+	recordAudit(c, AuditTokenCreate, "access_token", strconv.Itoa(int(tok.ID)), nil, accessTokenResponse(tok))
+
+	resp := accessTokenResponse(tok)
+	resp["token"] = raw
+	resp["message"] = "令牌已生成，明文只显示一次"
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func listAccessTokens(c echo.Context, ownerType, owner string) error {
+	var tokens []models.AccessToken
+	if err := config.DB.Where("owner_type = ? AND owner = ?", ownerType, owner).
+		Order("created_at desc").Find(&tokens).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(tokens))
+	for _, tok := range tokens {
+		items = append(items, accessTokenResponse(tok))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func revokeAccessToken(c echo.Context, ownerType, owner string) error {
+	id := c.Param("id")
+
+	var tok models.AccessToken
+	if err := config.DB.Where("owner_type = ? AND owner = ?", ownerType, owner).First(&tok, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "令牌不存在"})
+	}
+	if tok.RevokedAt != nil {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "令牌已撤销"})
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(&tok).Update("revoked_at", &now).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "撤销令牌失败"})
+	}
+	recordAudit(c, AuditTokenRevoke, "access_token", id, echo.Map{"revoked_at": nil}, echo.Map{"revoked_at": now})
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListAccessTokenScopes 列出可用的作用域
+func ListAccessTokenScopes(c echo.Context) error {
+	return c.JSON(http.StatusOK, scopeDescriptions)
+}
+
+// ListMyAccessTokens 列出当前成员的个人令牌
+func ListMyAccessTokens(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+	return listAccessTokens(c, tokenOwnerMember, cn)
+}
+
+// CreateMyAccessToken 当前成员创建个人令牌，令牌权限不超过本人权限
+func CreateMyAccessToken(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+	return createAccessToken(c, tokenOwnerMember, cn)
+}
+
+// RevokeMyAccessToken 当前成员撤销自己的个人令牌
+func RevokeMyAccessToken(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+	return revokeAccessToken(c, tokenOwnerMember, cn)
+}
+
+// ListServiceAccounts 列出服务账号
+func ListServiceAccounts(c echo.Context) error {
+	var accounts []models.ServiceAccount
+	if err := config.DB.Order("name").Find(&accounts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(accounts))
+	for _, a := range accounts {
+		var active int64
+		config.DB.Model(&models.AccessToken{}).
+			Where("owner_type = ? AND owner = ? AND revoked_at IS NULL", tokenOwnerService, a.Name).
+			Count(&active)
+		items = append(items, echo.Map{
+			"name":          a.Name,
+			"description":   a.Description,
+			"created_by":    a.CreatedBy,
+			"created_at":    a.CreatedAt,
+			"disabled_at":   a.DisabledAt,
+			"active_tokens": active,
+		})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// CreateServiceAccount 创建服务账号
+func CreateServiceAccount(c echo.Context) error {
+	type CreateRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req CreateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if !serviceAccountNamePattern.MatchString(req.Name) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "名称只能包含小写字母、数字、- 和 _，长度 2-32"})
+	}
+
+	var count int64
+	config.DB.Unscoped().Model(&models.ServiceAccount{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "服务账号已存在"})
+	}
+
+	account := models.ServiceAccount{Name: req.Name, Description: req.Description, CreatedBy: actorCN}
+	if err := config.DB.Create(&account).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "创建服务账号失败"})
+	}
+	recordAudit(c, AuditServiceAccountCreate, "service_account", account.Name, nil, echo.Map{"description": account.Description})
+	return c.JSON(http.StatusCreated, echo.Map{"name": account.Name, "description": account.Description})
+}
+
+// DisableServiceAccount 停用服务账号并撤销其全部令牌
+func DisableServiceAccount(c echo.Context) error {
+	name := c.Param("name")
+
+	var account models.ServiceAccount
+	if err := config.DB.Where("name = ?", name).First(&account).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "服务账号不存在"})
+	}
+	if account.DisabledAt != nil {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "服务账号已停用"})
+	}
+
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&account).Update("disabled_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AccessToken{}).
+			Where("owner_type = ? AND owner = ? AND revoked_at IS NULL", tokenOwnerService, name).
+			Update("revoked_at", &now).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "停用服务账号失败"})
+	}
+	recordAudit(c, AuditServiceAccountDisable, "service_account", name, echo.Map{"disabled_at": nil}, echo.Map{"disabled_at": now})
+	return c.NoContent(http.StatusNoContent)
+}
+
+// findActiveServiceAccount 查找未停用的服务账号，不存在时已写入响应
+func findActiveServiceAccount(c echo.Context) (string, bool, error) {
+	name := c.Param("name")
+	var account models.ServiceAccount
+	if err := config.DB.Where("name = ? AND disabled_at IS NULL", name).First(&account).Error; err != nil {
+		return "", false, c.JSON(http.StatusNotFound, echo.Map{"error": "服务账号不存在或已停用"})
+	}
+	return account.Name, true, nil
+}
+
+// ListServiceAccountTokens 列出服务账号的令牌
+func ListServiceAccountTokens(c echo.Context) error {
+	return listAccessTokens(c, tokenOwnerService, c.Param("name"))
+}
+
+// CreateServiceAccountToken 为服务账号签发令牌
+func CreateServiceAccountToken(c echo.Context) error {
+	name, ok, err := findActiveServiceAccount(c)
+	if !ok {
+		return err
+	}
+	return createAccessToken(c, tokenOwnerService, name)
+}
+
+// RevokeServiceAccountToken 撤销服务账号的令牌
+func RevokeServiceAccountToken(c echo.Context) error {
+	return revokeAccessToken(c, tokenOwnerService, c.Param("name"))
+}
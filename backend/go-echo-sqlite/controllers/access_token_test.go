@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func createPAT(t *testing.T, cn, scopes string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(`{"name":"ci","scopes":`+scopes+`}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_cn", cn)
+	if err := CreateMyAccessToken(c); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create token: %d %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body.Token
+}
+
+// scoped 与路由中相同的包装：声明作用域后再校验权限
+func scoped(scope, perm string) echo.HandlerFunc {
+	return RequireScope(scope)(RequirePermission(perm)(okHandler))
+}
+
+func TestAccessTokenPermissionsIntersectMemberAndScopes(t *testing.T) {
+	setupRBACTest(t)
+	newTestMember(t, "member", true)
+	newTestMember(t, "officer", true, RoleOfficer)
+	membersWrite := scoped(ScopeMembersWrite, PermMembersWrite)
+
+	// 作用域不能超出成员本人的权限
+	if rec := callAuthorized(membersWrite, createPAT(t, "member", `["members:write"]`)); rec.Code != http.StatusForbidden {
+		t.Fatalf("member token with members:write: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(membersWrite, createPAT(t, "officer", `["members:write"]`)); rec.Code != http.StatusOK {
+		t.Fatalf("officer token with members:write: %d %s", rec.Code, rec.Body)
+	}
+	// 成员的权限也不能超出令牌的作用域
+	activitiesOnly := createPAT(t, "officer", `["activities:write"]`)
+	if rec := callAuthorized(membersWrite, activitiesOnly); rec.Code != http.StatusForbidden {
+		t.Fatalf("token without the required scope: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(scoped(ScopeActivitiesWrite, PermActivitiesWrite), activitiesOnly); rec.Code != http.StatusOK {
+		t.Fatalf("token with the required scope: %d %s", rec.Code, rec.Body)
+	}
+	// 没有声明作用域的接口只接受登录会话
+	if rec := callAuthorized(VerifyToken(okHandler), activitiesOnly); rec.Code != http.StatusForbidden {
+		t.Fatalf("token on a session-only endpoint: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAccessTokenFollowsCurrentRoles(t *testing.T) {
+	setupRBACTest(t)
+	newTestMember(t, "admin", true, RoleAdmin)
+	newTestMember(t, "officer", true, RoleOfficer)
+	token := createPAT(t, "officer", `["members:write"]`)
+	membersWrite := scoped(ScopeMembersWrite, PermMembersWrite)
+
+	if rec := changeRole(RemoveMemberRole, http.MethodDelete, "officer", RoleOfficer, "admin"); rec.Code != http.StatusNoContent {
+		t.Fatalf("remove role: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(membersWrite, token); rec.Code != http.StatusForbidden {
+		t.Fatalf("token of a demoted officer: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAccessTokenRejectsRevokedAndExpired(t *testing.T) {
+	setupRBACTest(t)
+	newTestMember(t, "officer", true, RoleOfficer)
+	revoked := createPAT(t, "officer", `["members:write"]`)
+	expired := createPAT(t, "officer", `["members:write"]`)
+
+	now := time.Now()
+	config.DB.Model(&models.AccessToken{}).Where("token_hash = ?", hashToken(revoked)).Update("revoked_at", &now)
+	config.DB.Model(&models.AccessToken{}).Where("token_hash = ?", hashToken(expired)).Update("expires_at", now.Add(-time.Minute))
+
+	membersWrite := scoped(ScopeMembersWrite, PermMembersWrite)
+	for name, token := range map[string]string{"revoked": revoked, "expired": expired, "unknown": accessTokenPrefix + "nope"} {
+		if rec := callAuthorized(membersWrite, token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s token: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -26,5 +27,6 @@ func CreateActivity(c echo.Context) error {
 	if result.Error != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
 	}
+	recordAudit(c, AuditActivityCreate, "activity", strconv.FormatUint(uint64(activity.ID), 10), nil, activity)
 	return c.JSON(http.StatusCreated, activity)
 }
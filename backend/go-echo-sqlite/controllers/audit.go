@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 审计动作
+const (
+	AuditMemberRegister        = "member.register"
+	AuditMemberUpdate          = "member.update"
+	AuditMemberDelete          = "member.delete"
+	AuditProfileCreate         = "profile.create"
+	AuditProfileUpdate         = "profile.update"
+	AuditProfileDelete         = "profile.delete"
+	AuditActivityCreate        = "activity.create"
+	AuditRoleAssign            = "role.assign"
+	AuditRoleRemove            = "role.remove"
+	AuditSessionsRevoke        = "sessions.revoke"
+	AuditKeyRotate             = "jwt.rotate"
+	AuditPasswordResetIssue    = "password_reset.issue"
+	AuditMemoryCodeIssue       = "memory_code.issue"
+	AuditAccountUnlock         = "account.unlock"
+	AuditTOTPEnable            = "totp.enable"
+	AuditTOTPDisable           = "totp.disable"
+	AuditInvitationCreate      = "invitation.create"
+	AuditInvitationRevoke      = "invitation.revoke"
+	AuditApplicationApprove    = "application.approve"
+	AuditApplicationReject     = "application.reject"
+	AuditTokenCreate           = "access_token.create"
+	AuditTokenRevoke           = "access_token.revoke"
+	AuditServiceAccountCreate  = "service_account.create"
+	AuditServiceAccountDisable = "service_account.disable"
+	AuditIdentityLink          = "identity.link"
+	AuditIdentityUnlink        = "identity.unlink"
+	AuditTrashRestore          = "trash.restore"
+	AuditTrashPurge            = "trash.purge"
+	AuditMemberRename          = "member.rename"
+	AuditTenureCreate          = "tenure.create"
+	AuditTenureUpdate          = "tenure.update"
+	AuditTenureDelete          = "tenure.delete"
+	AuditMemberImport          = "member.import"
+	AuditMemberExport          = "member.export"
+	AuditMemberStatus          = "member.status"
+	AuditRolloverApply         = "rollover.apply"
+	AuditRolloverRollback      = "rollover.rollback"
+	AuditOrgPositionCreate     = "org_position.create"
+	AuditOrgPositionUpdate     = "org_position.update"
+	AuditOrgPositionDelete     = "org_position.delete"
+	AuditOrgAssign             = "org_position.assign"
+	AuditOrgUnassign           = "org_position.unassign"
+	AuditVocabCreate           = "vocab.create"
+	AuditVocabUpdate           = "vocab.update"
+	AuditVocabDelete           = "vocab.delete"
+	AuditVocabMigrate          = "vocab.migrate"
+	AuditPrivacyUpdate         = "member.privacy"
+)
+
+// toAuditMap 将快照转换为 map，便于比较字段差异
+func toAuditMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil
+	}
+	// gorm.Model 的时间戳每次写入都会变化，不计入差异
+	delete(m, "CreatedAt")
+	delete(m, "UpdatedAt")
+	delete(m, "DeletedAt")
+	return m
+}
+
+// auditDiff 只保留前后不同的字段
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	b := map[string]interface{}{}
+	a := map[string]interface{}{}
+	for k, av := range after {
+		if bv, ok := before[k]; !ok || !reflect.DeepEqual(bv, av) {
+			b[k] = before[k]
+			a[k] = av
+		}
+	}
+	for k, bv := range before {
+		if _, ok := after[k]; !ok {
+			b[k] = bv
+		}
+	}
+	return b, a
+}
+
+func auditJSON(m map[string]interface{}) string {
+	if m == nil {
+		return ""
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+func rawAuditJSON(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// recordAudit 写入一条审计记录；before/after 为操作前后的快照（可为 nil），更新操作只记录变化的字段
+func recordAudit(c echo.Context, action, targetType, target string, before, after interface{}) {
+	actorCN, _ := c.Get("user_cn").(string)
+	b, a := auditDiff(toAuditMap(before), toAuditMap(after))
+
+	entry := models.AuditLog{
+		ActorCN:    actorCN,
+		Action:     action,
+		TargetType: targetType,
+		Target:     target,
+		Before:     auditJSON(b),
+		After:      auditJSON(a),
+		IP:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		fmt.Printf("写入审计日志失败: %v\n", err)
+	}
+}
+
+// parseAuditTime 支持 RFC3339 与 2006-01-02 两种格式
+func parseAuditTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// GetAuditLogs 查询审计日志，支持 actor、target、action、since、until 过滤
+func GetAuditLogs(c echo.Context) error {
+	query := config.DB.Model(&models.AuditLog{})
+
+	if actor := c.QueryParam("actor"); actor != "" {
+		query = query.Where("actor_cn = ?", actor)
+	}
+	if target := c.QueryParam("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	if action := c.QueryParam("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if since := c.QueryParam("since"); since != "" {
+		t, err := parseAuditTime(since)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "since 时间格式错误"})
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if until := c.QueryParam("until"); until != "" {
+		t, err := parseAuditTime(until)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "until 时间格式错误"})
+		}
+		// 仅给出日期时包含当天
+		if len(until) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(logs))
+	for _, l := range logs {
+		items = append(items, echo.Map{
+			"id":          l.ID,
+			"actor_cn":    l.ActorCN,
+			"action":      l.Action,
+			"target_type": l.TargetType,
+			"target":      l.Target,
+			"before":      rawAuditJSON(l.Before),
+			"after":       rawAuditJSON(l.After),
+			"ip":          l.IP,
+			"user_agent":  l.UserAgent,
+			"created_at":  l.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"items":  items,
+	})
+}
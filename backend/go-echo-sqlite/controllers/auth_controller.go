@@ -1,21 +1,27 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-var jwtSecret = []byte("seventhcentury-secret-key")
-
 type Claims struct {
-	CN       string `json:"cn"`
-	IsMember bool   `json:"is_member"`
+	CN          string   `json:"cn"`
+	IsMember    bool     `json:"is_member"`
+	SessionID   string   `json:"sid"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"perms"`
+	MFA         bool     `json:"mfa"`
 	jwt.RegisteredClaims
 }
 
@@ -35,53 +41,87 @@ func Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "用户名和密码不能为空"})
 	}
 
+	if blocked, err := authThrottled(c, req.CN); blocked {
+		return err
+	}
+
 	// 查找用户
 	var member models.ClubMember
 	result := config.DB.Where("cn = ?", req.CN).First(&member)
 	if result.Error != nil {
+		recordAuthFailure(c, authActionLogin, req.CN)
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "用户名或密码错误"})
 	}
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(member.Password), []byte(req.Password)); err != nil {
+		recordAuthFailure(c, authActionLogin, req.CN)
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "用户名或密码错误"})
 	}
+	recordAuthSuccess(c, authActionLogin, member.CN)
+
+	// 旧哈希的 cost 低于当前配置时，借本次登录的明文密码透明升级
+	if passwordNeedsRehash(member.Password) {
+		if hashed, err := hashPassword(req.Password); err == nil {
+			if err := config.DB.Model(&member).Update("password", hashed).Error; err != nil {
+				fmt.Printf("升级密码哈希失败: %v\n", err)
+			}
+		}
+	}
+
+	return c.JSON(loginResult(c, member))
+}
+
+// loginResult 身份校验通过后的登录结果：已启用两步验证时返回 challenge，否则创建会话
+func loginResult(c echo.Context, member models.ClubMember) (int, echo.Map) {
+	// 已启用两步验证：返回 challenge，由 /api/login/2fa 完成登录
+	if member.TOTPEnabled {
+		challenge, err := issueMFAChallenge(member.CN, mfaPurposeLogin)
+		if err != nil {
+			return http.StatusInternalServerError, echo.Map{"error": "生成token失败"}
+		}
+		return http.StatusOK, echo.Map{
+			"two_factor_required": true,
+			"challenge":           challenge,
+			"cn":                  member.CN,
+			"message":             "请输入两步验证码",
+		}
+	}
 
-	// 生成JWT
-	claims := &Claims{
-		CN:       member.CN,
-		IsMember: member.IsMember,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	// 管理员被要求启用两步验证但尚未启用：只发放用于绑定验证器的 enrollment_token
+	if totpRequiredForAdmins() && isMCPAdminCN(member.CN) {
+		enrollmentToken, err := issueMFAChallenge(member.CN, mfaPurposeEnroll)
+		if err != nil {
+			return http.StatusInternalServerError, echo.Map{"error": "生成token失败"}
+		}
+		return http.StatusForbidden, echo.Map{
+			"error":                          "管理员账号必须先启用两步验证",
+			"two_factor_enrollment_required": true,
+			"enrollment_token":               enrollmentToken,
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	// 创建会话并生成JWT
+	accessToken, refreshToken, err := startSession(c, member, false)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成token失败"})
+		return http.StatusInternalServerError, echo.Map{"error": "生成token失败"}
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{
-		"token":     tokenString,
-		"cn":        member.CN,
-		"is_member": member.IsMember,
-		"message":   "登录成功",
-	})
+	return http.StatusOK, loginResponse(member, accessToken, refreshToken)
 }
 
-// Register 用户注册
+// Register 用户注册：提供有效邀请码时直接成为社团成员，否则注册为待审核的访客
 func Register(c echo.Context) error {
 	type RegisterRequest struct {
-		CN        string `json:"cn"`
-		Password  string `json:"password"`
-		Sex       string `json:"sex"`
-		Position  string `json:"position"`
-		Year      string `json:"year"`
-		Direction string `json:"direction"`
-		Status    string `json:"status"`
-		Remark    string `json:"remark"`
+		CN             string `json:"cn"`
+		Password       string `json:"password"`
+		Sex            string `json:"sex"`
+		Position       string `json:"position"`
+		Year           string `json:"year"`
+		Direction      string `json:"direction"`
+		Status         string `json:"status"`
+		Remark         string `json:"remark"`
+		InvitationCode string `json:"invitation_code"`
 	}
 
 	var req RegisterRequest
@@ -93,19 +133,25 @@ func Register(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "用户名和密码不能为空"})
 	}
 
-	if len(req.Password) < 6 && req.Password != "0721" {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "密码长度至少6位"})
+	if err := checkPasswordPolicy(req.CN, req.Password); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if err := normalizeMemberInput(memberVocabFields{Sex: &req.Sex, Position: &req.Position, Year: &req.Year, Direction: &req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
-	// 检查用户是否已存在
+	// 检查用户是否已存在（回收站中的成员同样占用该用户名）
 	var existingMember models.ClubMember
-	result := config.DB.Where("cn = ?", req.CN).First(&existingMember)
+	result := config.DB.Unscoped().Where("cn = ?", req.CN).First(&existingMember)
 	if result.Error == nil {
 		return c.JSON(http.StatusConflict, echo.Map{"error": "用户名已存在"})
 	}
+	if isMemberAlias(req.CN) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": errRenameAlias.Error()})
+	}
 
 	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.Password)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "密码加密失败"})
 	}
@@ -113,27 +159,83 @@ func Register(c echo.Context) error {
 	// 创建新用户
 	member := models.ClubMember{
 		CN:        req.CN,
-		Password:  string(hashedPassword),
+		Password:  hashedPassword,
 		Sex:       req.Sex,
 		Position:  req.Position,
 		Year:      req.Year,
 		Direction: req.Direction,
-		Status:    req.Status,
-		IsMember:  true, // 注册的用户默认为社团成员
 		Remark:    req.Remark,
 	}
 
-	if err := config.DB.Create(&member).Error; err != nil {
+	var invitation models.InvitationCode
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if req.InvitationCode != "" {
+			inv, err := redeemInvitation(tx, req.InvitationCode)
+			if err != nil {
+				return err
+			}
+			invitation = inv
+			if inv.Year != "" {
+				member.Year = inv.Year
+			}
+			if inv.Direction != "" {
+				member.Direction = inv.Direction
+			}
+		}
+
+		member.Status = initialMemberStatus(req.Status, invitation.ID != 0)
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.StatusChange{CN: member.CN, ToStatus: member.Status, Reason: "注册", ChangedBy: member.CN}).Error; err != nil {
+			return err
+		}
+		// is_member 列默认值为 true，零值不会写入，需显式更新
+		if invitation.ID == 0 {
+			member.IsMember = false
+			if err := tx.Model(&member).Update("is_member", false).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.MembershipApplication{CN: member.CN, Status: ApplicationPending}).Error
+		}
+		if invitation.Role != "" && invitation.Role != RoleMember {
+			return tx.Create(&models.MemberRole{
+				CN:        member.CN,
+				RoleName:  invitation.Role,
+				GrantedBy: "invitation:" + strconv.Itoa(int(invitation.ID)),
+			}).Error
+		}
+		return nil
+	})
+	if errors.Is(err, errInvitationInvalid) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "注册失败"})
 	}
 
+	c.Set("user_cn", member.CN)
+	recordAudit(c, AuditMemberRegister, "member", member.CN, nil, echo.Map{
+		"is_member":  member.IsMember,
+		"invitation": invitation.ID,
+		"role":       invitation.Role,
+	})
+
+	if !member.IsMember {
+		return c.JSON(http.StatusCreated, echo.Map{
+			"message":   "注册成功，请等待干部审核后成为社团成员",
+			"cn":        member.CN,
+			"is_member": false,
+		})
+	}
 	return c.JSON(http.StatusCreated, echo.Map{
-		"message": "注册成功",
-		"cn":      member.CN,
+		"message":   "注册成功",
+		"cn":        member.CN,
+		"is_member": true,
 	})
 }
 
-// MCPRegister 受保护的注册：必须是成员权限，且 token 中 cn 必须与请求 cn 一致
+// MCPRegister 受保护的注册：必须是成员权限，且 token 中 cn 必须与请求 cn 一致（拥有 members:write 权限可为他人注册）
 func MCPRegister(c echo.Context) error {
 	type RegisterRequest struct {
 		CN        string `json:"cn"`
@@ -159,43 +261,56 @@ func MCPRegister(c echo.Context) error {
 	if req.CN == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "用户名不能为空"})
 	}
-	if actorCN != req.CN && !isMCPAdminCN(actorCN) {
+	if actorCN != req.CN && !hasPermission(c, PermMembersWrite) {
 		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权为他人注册"})
 	}
 	if req.Password == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "密码不能为空"})
 	}
-	if len(req.Password) < 6 && req.Password != "0721" {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "密码长度至少6位"})
+	if err := checkPasswordPolicy(req.CN, req.Password); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if err := normalizeMemberInput(memberVocabFields{Sex: &req.Sex, Position: &req.Position, Year: &req.Year, Direction: &req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
 	// 复用原 Register 的逻辑（本函数内直接实现，避免改动原接口行为）
 	var existingMember models.ClubMember
-	result := config.DB.Where("cn = ?", req.CN).First(&existingMember)
+	result := config.DB.Unscoped().Where("cn = ?", req.CN).First(&existingMember)
 	if result.Error == nil {
 		return c.JSON(http.StatusConflict, echo.Map{"error": "用户名已存在"})
 	}
+	if isMemberAlias(req.CN) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": errRenameAlias.Error()})
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.Password)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "密码加密失败"})
 	}
 
 	member := models.ClubMember{
 		CN:        req.CN,
-		Password:  string(hashedPassword),
+		Password:  hashedPassword,
 		Sex:       req.Sex,
 		Position:  req.Position,
 		Year:      req.Year,
 		Direction: req.Direction,
-		Status:    req.Status,
+		Status:    initialMemberStatus(req.Status, true),
 		IsMember:  true,
 		Remark:    req.Remark,
 	}
 
-	if err := config.DB.Create(&member).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.StatusChange{CN: member.CN, ToStatus: member.Status, Reason: "注册", ChangedBy: actorCN}).Error
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "注册失败"})
 	}
+	recordAudit(c, AuditMemberRegister, "member", member.CN, nil, toClubMemberPublic(member, nil))
 
 	return c.JSON(http.StatusCreated, echo.Map{
 		"message": "注册成功",
@@ -203,32 +318,71 @@ func MCPRegister(c echo.Context) error {
 	})
 }
 
-// VerifyToken 验证JWT中间件
-func VerifyToken(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		tokenString := c.Request().Header.Get("Authorization")
-		if tokenString == "" {
-			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "未提供认证token"})
-		}
+// authenticateRequest 校验 Authorization 头中的登录 token 或访问令牌，通过后将用户信息写入 context
+// 失败时返回应答的状态码与错误
+func authenticateRequest(c echo.Context) (int, error) {
+	tokenString := c.Request().Header.Get("Authorization")
+	if tokenString == "" {
+		return http.StatusUnauthorized, errors.New("未提供认证token")
+	}
+
+	// 移除 "Bearer " 前缀
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
 
-		// 移除 "Bearer " 前缀
-		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-			tokenString = tokenString[7:]
+	// 访问令牌（个人令牌/服务账号）只能调用通过 RequireScope 声明了作用域的接口
+	if strings.HasPrefix(tokenString, accessTokenPrefix) {
+		if err := authenticateAccessToken(c, tokenString); err != nil {
+			if errors.Is(err, errAccessTokenScope) {
+				return http.StatusForbidden, err
+			}
+			return http.StatusUnauthorized, err
 		}
+		applyAdminMFAMandate(c)
+		return http.StatusOK, nil
+	}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+	claims := &Claims{}
+	token, err := parseToken(tokenString, claims)
 
-		if err != nil || !token.Valid {
-			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "无效的token"})
-		}
+	if err != nil || !token.Valid {
+		return http.StatusUnauthorized, errors.New("无效的token")
+	}
 
-		// 将用户信息存储到context中
-		c.Set("user_cn", claims.CN)
-		c.Set("is_member", claims.IsMember)
+	// 会话被注销或撤销后，尚未过期的访问 token 也一并失效
+	if !sessionActive(claims.SessionID) {
+		return http.StatusUnauthorized, errors.New("会话已失效，请重新登录")
+	}
 
+	// 将用户信息存储到context中
+	c.Set("user_cn", claims.CN)
+	c.Set("is_member", claims.IsMember)
+	c.Set("session_id", claims.SessionID)
+	c.Set("roles", claims.Roles)
+	c.Set("permissions", claims.Permissions)
+	c.Set("mfa", claims.MFA)
+	applyAdminMFAMandate(c)
+	return http.StatusOK, nil
+}
+
+// VerifyToken 验证JWT中间件
+func VerifyToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if status, err := authenticateRequest(c); err != nil {
+			return c.JSON(status, echo.Map{"error": err.Error()})
+		}
+		return next(c)
+	}
+}
+
+// OptionalAuth 公开接口使用：携带有效 token 时识别查看者身份，未携带或 token 无效时按访客处理
+// 用于按成员的隐私设置决定返回哪些字段
+func OptionalAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Header.Get("Authorization") != "" {
+			_, _ = authenticateRequest(c)
+		}
 		return next(c)
 	}
 }
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRegisterRejectsFormerCN(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("BCRYPT_COST", "4")
+	// old 改名为 new 后，old 只能由 new 收回
+	if err := config.DB.Create(&models.ClubMember{CN: "new", Password: "x", IsMember: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.MemberAlias{OldCN: "old", CN: "new"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"cn":"old","password":"Sup3rSecret!"}`
+	for name, h := range map[string]echo.HandlerFunc{"Register": Register, "MCPRegister": MCPRegister} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.Set("user_cn", "old")
+		if err := h(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	var count int64
+	config.DB.Model(&models.ClubMember{}).Where("cn = ?", "old").Count(&count)
+	if count != 0 {
+		t.Fatal("former CN was registered")
+	}
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 认证限流配置：
+//   AUTH_RATE_WINDOW         限流滑动窗口，默认 1 分钟
+//   AUTH_IP_LIMIT            每个 IP 在窗口内允许的尝试次数，默认 20
+//   AUTH_CN_LIMIT            每个 CN 在窗口内允许的尝试次数，默认 10
+//   AUTH_LOCKOUT_THRESHOLD   连续失败多少次后锁定账号，默认 5
+//   AUTH_LOCKOUT_DURATION    锁定时长，默认 15 分钟
+//   TRUSTED_PROXIES          反向代理的 IP 或网段（逗号分隔，如 127.0.0.1/32）；只有来自这些地址的请求
+//                            才采信 X-Forwarded-For，未设置时一律使用连接的对端地址
+
+const (
+	authActionLogin          = "login"
+	authActionLoginTOTP      = "login_2fa"
+	authActionLoginOIDC      = "login_oidc"
+	authActionForgotPassword = "forgot_password"
+	authActionChangePassword = "change_password"
+	authActionResetPassword  = "reset_password"
+	authActionUnlock         = "unlock"
+)
+
+// attemptRetention 认证尝试记录的保留时长：至少 24 小时，且不短于锁定时长与限流窗口，否则锁定会被提前清掉
+func attemptRetention() time.Duration {
+	retention := 24 * time.Hour
+	for _, d := range []time.Duration{lockoutDuration(), authRateWindow()} {
+		if d > retention {
+			retention = d
+		}
+	}
+	return retention
+}
+
+// IPExtractor 按 TRUSTED_PROXIES 决定客户端 IP 的来源，限流与审计都依赖它；
+// 默认不信任任何转发头，防止客户端伪造 X-Forwarded-For / X-Real-IP 绕过按 IP 限流
+func IPExtractor() echo.IPExtractor {
+	raw := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES"))
+	if raw == "" {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if strings.Contains(item, ":") {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			fmt.Printf("⚠ 忽略无效的 TRUSTED_PROXIES 项: %s\n", item)
+			continue
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// CleanupAuthAttempts 删除超过保留期的认证尝试记录
+func CleanupAuthAttempts() {
+	config.DB.Where("created_at < ?", time.Now().Add(-attemptRetention())).Delete(&models.AuthAttempt{})
+}
+
+// StartAuthAttemptCleanup 每小时清理一次过期的认证尝试记录
+func StartAuthAttemptCleanup() {
+	go func() {
+		CleanupAuthAttempts()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			CleanupAuthAttempts()
+		}
+	}()
+}
+
+func authRateWindow() time.Duration  { return envDuration("AUTH_RATE_WINDOW", time.Minute) }
+func authIPLimit() int               { return envInt("AUTH_IP_LIMIT", 20) }
+func authCNLimit() int               { return envInt("AUTH_CN_LIMIT", 10) }
+func lockoutThreshold() int          { return envInt("AUTH_LOCKOUT_THRESHOLD", 5) }
+func lockoutDuration() time.Duration { return envDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute) }
+
+// consecutiveFailures 返回成员自上次成功（或解锁）以来、锁定时长内的失败次数及最近一次失败时间
+func consecutiveFailures(cn string) (int64, time.Time) {
+	since := time.Now().Add(-lockoutDuration())
+
+	var lastSuccess models.AuthAttempt
+	if err := config.DB.Where("cn = ? AND success = ?", cn, true).Order("created_at desc").
+		First(&lastSuccess).Error; err == nil && lastSuccess.CreatedAt.After(since) {
+		since = lastSuccess.CreatedAt
+	}
+
+	var count int64
+	config.DB.Model(&models.AuthAttempt{}).
+		Where("cn = ? AND success = ? AND created_at > ?", cn, false, since).
+		Count(&count)
+
+	var lastFailure models.AuthAttempt
+	config.DB.Where("cn = ? AND success = ?", cn, false).Order("created_at desc").First(&lastFailure)
+	return count, lastFailure.CreatedAt
+}
+
+// lockedUntil 返回账号锁定的截止时间，未锁定时返回零值
+func lockedUntil(cn string) time.Time {
+	failures, last := consecutiveFailures(cn)
+	if failures < int64(lockoutThreshold()) {
+		return time.Time{}
+	}
+	until := last.Add(lockoutDuration())
+	if time.Now().After(until) {
+		return time.Time{}
+	}
+	return until
+}
+
+// progressiveDelay 按连续失败次数递增的响应延迟：第 2 次起 250ms，逐次翻倍，最多 4s
+func progressiveDelay(failures int64) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	delay := 250 * time.Millisecond << uint(failures-2)
+	if delay > 4*time.Second || delay <= 0 {
+		delay = 4 * time.Second
+	}
+	return delay
+}
+
+// authThrottled 检查 IP/CN 限流与账号锁定；被拦截时已写入响应，调用方直接返回 err
+func authThrottled(c echo.Context, cn string) (bool, error) {
+	window := time.Now().Add(-authRateWindow())
+	retryAfter := strconv.Itoa(int(authRateWindow().Seconds()))
+
+	var ipCount int64
+	config.DB.Model(&models.AuthAttempt{}).
+		Where("ip = ? AND action <> ? AND created_at > ?", c.RealIP(), authActionUnlock, window).
+		Count(&ipCount)
+	if ipCount >= int64(authIPLimit()) {
+		c.Response().Header().Set("Retry-After", retryAfter)
+		return true, c.JSON(http.StatusTooManyRequests, echo.Map{"error": "请求过于频繁，请稍后再试"})
+	}
+
+	if cn == "" {
+		return false, nil
+	}
+
+	var cnCount int64
+	config.DB.Model(&models.AuthAttempt{}).
+		Where("cn = ? AND action <> ? AND created_at > ?", cn, authActionUnlock, window).
+		Count(&cnCount)
+	if cnCount >= int64(authCNLimit()) {
+		c.Response().Header().Set("Retry-After", retryAfter)
+		return true, c.JSON(http.StatusTooManyRequests, echo.Map{"error": "该账号尝试过于频繁，请稍后再试"})
+	}
+
+	if until := lockedUntil(cn); !until.IsZero() {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+		return true, c.JSON(http.StatusLocked, echo.Map{
+			"error":        "失败次数过多，账号已临时锁定",
+			"locked_until": until,
+		})
+	}
+	return false, nil
+}
+
+func recordAuthAttempt(c echo.Context, action, cn string, success bool) {
+	attempt := models.AuthAttempt{Action: action, CN: cn, IP: c.RealIP(), Success: success}
+	if err := config.DB.Create(&attempt).Error; err != nil {
+		fmt.Printf("记录认证尝试失败: %v\n", err)
+	}
+}
+
+// recordAuthFailure 记录一次失败，并按连续失败次数延迟响应
+func recordAuthFailure(c echo.Context, action, cn string) {
+	recordAuthAttempt(c, action, cn, false)
+	if cn == "" {
+		return
+	}
+	failures, _ := consecutiveFailures(cn)
+	time.Sleep(progressiveDelay(failures))
+}
+
+// recordAuthSuccess 记录一次成功，清零连续失败计数
+func recordAuthSuccess(c echo.Context, action, cn string) {
+	recordAuthAttempt(c, action, cn, true)
+}
+
+// ListLockouts 列出当前被锁定的账号
+func ListLockouts(c echo.Context) error {
+	var cns []string
+	config.DB.Model(&models.AuthAttempt{}).
+		Where("success = ? AND created_at > ?", false, time.Now().Add(-lockoutDuration())).
+		Distinct("cn").Pluck("cn", &cns)
+
+	items := make([]echo.Map, 0)
+	for _, cn := range cns {
+		if cn == "" {
+			continue
+		}
+		if until := lockedUntil(cn); !until.IsZero() {
+			failures, _ := consecutiveFailures(cn)
+			items = append(items, echo.Map{"cn": cn, "failures": failures, "locked_until": until})
+		}
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// UnlockAccount 管理员解除账号锁定
+func UnlockAccount(c echo.Context) error {
+	cn := c.Param("cn")
+	before := lockedUntil(cn)
+
+	// 写入一条成功记录作为连续失败计数的起点
+	unlock := models.AuthAttempt{Action: authActionUnlock, CN: cn, Success: true}
+	if err := config.DB.Create(&unlock).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "解锁失败"})
+	}
+	if !before.IsZero() {
+		recordAudit(c, AuditAccountUnlock, "member", cn, echo.Map{"locked_until": before}, nil)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "账号已解锁", "cn": cn})
+}
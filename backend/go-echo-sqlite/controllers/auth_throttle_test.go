@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+const throttlePassword = "Sup3rSecret!"
+
+func setupThrottleTest(t *testing.T) *echo.Echo {
+	t.Helper()
+	newTestDB(t)
+	t.Setenv("BCRYPT_COST", "4")
+	t.Setenv("TRUSTED_PROXIES", "")
+	t.Setenv("AUTH_IP_LIMIT", "100")
+	t.Setenv("AUTH_CN_LIMIT", "100")
+	t.Setenv("AUTH_LOCKOUT_THRESHOLD", "3")
+	hashed, err := hashPassword(throttlePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := newTestMember(t, "alice", true)
+	if err := config.DB.Model(&alice).Update("password", hashed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.IPExtractor = IPExtractor()
+	return e
+}
+
+// login 从 ip 发起登录，xff 非空时附带 X-Forwarded-For
+func login(e *echo.Echo, cn, password, ip, xff string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"cn":"`+cn+`","password":"`+password+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.RemoteAddr = ip + ":40000"
+	if xff != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, xff)
+	}
+	rec := httptest.NewRecorder()
+	Login(e.NewContext(req, rec))
+	return rec
+}
+
+func TestLoginLocksAccountAfterConsecutiveFailures(t *testing.T) {
+	e := setupThrottleTest(t)
+	for i := 0; i < 3; i++ {
+		if rec := login(e, "alice", "wrong", "192.0.2.1", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: %d %s", i+1, rec.Code, rec.Body)
+		}
+	}
+	// 锁定期间正确的密码也被拒绝
+	rec := login(e, "alice", throttlePassword, "192.0.2.2", "")
+	if rec.Code != http.StatusLocked || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked account: %d %s", rec.Code, rec.Body)
+	}
+
+	c, rec := newTestContext("alice", map[string]interface{}{"user_cn": "admin"})
+	if err := UnlockAccount(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unlock: %d %s", rec.Code, rec.Body)
+	}
+	if rec := login(e, "alice", throttlePassword, "192.0.2.2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("login after unlock: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLoginSuccessResetsFailureCount(t *testing.T) {
+	e := setupThrottleTest(t)
+	for _, password := range []string{"wrong", "wrong", throttlePassword, "wrong", "wrong"} {
+		login(e, "alice", password, "192.0.2.1", "")
+	}
+	if rec := login(e, "alice", throttlePassword, "192.0.2.1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("login after interleaved success: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLoginRateLimitsByIPIgnoringSpoofedHeaders(t *testing.T) {
+	e := setupThrottleTest(t)
+	t.Setenv("AUTH_IP_LIMIT", "3")
+	// 不同的 CN 与伪造的 X-Forwarded-For 都不能绕过按 IP 限流
+	for i, xff := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		if rec := login(e, "guess"+strconv.Itoa(i), "wrong", "192.0.2.1", xff); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: %d %s", i+1, rec.Code, rec.Body)
+		}
+	}
+	rec := login(e, "alice", throttlePassword, "192.0.2.1", "203.0.113.9")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over IP limit: %d %s", rec.Code, rec.Body)
+	}
+	if rec := login(e, "alice", throttlePassword, "192.0.2.2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("other IP: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLoginRateLimitsByCNAcrossIPs(t *testing.T) {
+	e := setupThrottleTest(t)
+	t.Setenv("AUTH_CN_LIMIT", "2")
+	t.Setenv("AUTH_LOCKOUT_THRESHOLD", "100")
+	login(e, "alice", "wrong", "192.0.2.1", "")
+	login(e, "alice", "wrong", "192.0.2.2", "")
+	if rec := login(e, "alice", throttlePassword, "192.0.2.3", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over CN limit: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -1,49 +1,194 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ClubMemberPublic struct {
-	CN        string `json:"cn"`
-	Sex       string `json:"sex"`
-	Position  string `json:"position"`
-	Year      string `json:"year"`
-	Direction string `json:"direction"`
-	Status    string `json:"status"`
-	IsMember  bool   `json:"is_member"`
-	Remark    string `json:"remark"`
+	CN          string `json:"cn"`
+	Sex         string `json:"sex"`
+	Position    string `json:"position"`
+	Year        string `json:"year"`
+	Direction   string `json:"direction"`
+	Status      string `json:"status"`
+	StatusLabel string `json:"status_label"`
+	IsMember    bool   `json:"is_member"`
+	Remark      string `json:"remark"`
+	// HiddenFields 按成员隐私设置对当前查看者隐藏的字段，这些字段返回空值
+	HiddenFields []string `json:"hidden_fields,omitempty"`
 }
 
-func toClubMemberPublic(member models.ClubMember) ClubMemberPublic {
-	return ClubMemberPublic{
-		CN:        member.CN,
-		Sex:       member.Sex,
-		Position:  member.Position,
-		Year:      member.Year,
-		Direction: member.Direction,
-		Status:    member.Status,
-		IsMember:  member.IsMember,
-		Remark:    member.Remark,
+// clubMemberPrivacyFields ClubMemberPublic 中受隐私设置控制的字段
+var clubMemberPrivacyFields = []string{"sex", "year", "direction", "remark"}
+
+// toClubMemberPublic 转换为对外返回的成员信息，view 为 nil 时返回全部字段
+func toClubMemberPublic(member models.ClubMember, view *privacyView) ClubMemberPublic {
+	public := ClubMemberPublic{
+		CN:          member.CN,
+		Sex:         member.Sex,
+		Position:    member.Position,
+		Year:        member.Year,
+		Direction:   member.Direction,
+		Status:      member.Status,
+		StatusLabel: models.MemberStatusLabel(member.Status),
+		IsMember:    member.IsMember,
+		Remark:      member.Remark,
+	}
+	if view == nil {
+		return public
+	}
+	public.HiddenFields = view.hiddenFields(member.CN, clubMemberPrivacyFields)
+	for _, field := range public.HiddenFields {
+		switch field {
+		case "sex":
+			public.Sex = ""
+		case "year":
+			public.Year = ""
+		case "direction":
+			public.Direction = ""
+		case "remark":
+			public.Remark = ""
+		}
+	}
+	return public
+}
+
+// 成员列表可排序的字段
+var clubMemberSortFields = map[string]string{
+	"cn":         "cn",
+	"year":       "year",
+	"direction":  "direction",
+	"position":   "position",
+	"status":     "status",
+	"created_at": "created_at",
+}
+
+// splitQueryValues 支持逗号分隔的多个取值，如 status=在役,仍然在役
+func splitQueryValues(raw string) []string {
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
 	}
+	return values
 }
 
-// 获取所有社团成员
+// clubMemberQuery 按成员列表的过滤与排序参数构造查询，成员列表与导出共用
+// 过滤：year、direction、status（标准状态或中文名称）、position（均可逗号分隔多个值）、is_member、q（模糊匹配 CN 与备注）
+// 按年级、方向筛选与按备注搜索时，只匹配查看者能看到对应字段的成员；查看者看不到全部成员的年级或方向时不能按其排序
+// 排序：sort=cn|year|direction|position|status|created_at，order=asc|desc
+func clubMemberQuery(c echo.Context) (*gorm.DB, error) {
+	query := config.DB.Model(&models.ClubMember{})
+
+	for _, field := range []string{"year", "direction", "status", "position"} {
+		values := splitQueryValues(c.QueryParam(field))
+		if field == "status" {
+			// 同时接受标准状态与中文写法
+			for i, v := range values {
+				if s, ok := normalizeMemberStatus(v); ok {
+					values[i] = s
+				}
+			}
+		}
+		if len(values) > 0 {
+			query = query.Where(field+" IN ?", values)
+			if cond, cns := privacyCondition(c, field); cond != "" {
+				query = query.Where(cond, cns)
+			}
+		}
+	}
+	if raw := c.QueryParam("is_member"); raw != "" {
+		isMember, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("is_member 只能为 true 或 false")
+		}
+		query = query.Where("is_member = ?", isMember)
+	}
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		like := "%" + q + "%"
+		if cond, cns := privacyCondition(c, "remark"); cond != "" {
+			query = query.Where("cn LIKE ? OR (remark LIKE ? AND "+cond+")", like, like, cns)
+		} else {
+			query = query.Where("cn LIKE ? OR remark LIKE ?", like, like)
+		}
+	}
+
+	order := "id asc"
+	if sort := c.QueryParam("sort"); sort != "" {
+		column, ok := clubMemberSortFields[sort]
+		if !ok {
+			return nil, errors.New("不支持的排序字段")
+		}
+		// 排序结果同样会暴露隐藏的值：查看者看不到部分成员的该字段时不允许按它排序
+		if containsString(clubMemberPrivacyFields, sort) {
+			if cond, _ := privacyCondition(c, sort); cond != "" {
+				return nil, errors.New("部分成员隐藏了该字段，无法按它排序")
+			}
+		}
+		direction := "asc"
+		if strings.EqualFold(c.QueryParam("order"), "desc") {
+			direction = "desc"
+		}
+		// 以 id 作为次级排序，保证翻页结果稳定
+		order = column + " " + direction + ", id " + direction
+	}
+	return query.Order(order), nil
+}
+
+// GetClubMembers 获取社团成员列表，过滤与排序参数见 clubMemberQuery
+// 分页：page（从 1 开始）、page_size（最大 200）；未指定 page_size 时返回全部结果
 func GetClubMembers(c echo.Context) error {
+	query, err := clubMemberQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
+	if pageSize > 200 {
+		pageSize = 200
+	}
+	if pageSize > 0 {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
 	var members []models.ClubMember
-	result := config.DB.Select("cn", "sex", "position", "year", "direction", "status", "is_member", "remark").Find(&members)
+	result := query.Select("cn", "sex", "position", "year", "direction", "status", "is_member", "remark").Find(&members)
 	if result.Error != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
 	}
+	cns := make([]string, 0, len(members))
+	for _, m := range members {
+		cns = append(cns, m.CN)
+	}
+	view := newPrivacyView(c, cns...)
 	publicMembers := make([]ClubMemberPublic, 0, len(members))
 	for _, m := range members {
-		publicMembers = append(publicMembers, toClubMemberPublic(m))
+		publicMembers = append(publicMembers, toClubMemberPublic(m, view))
 	}
-	return c.JSON(http.StatusOK, publicMembers)
+	return c.JSON(http.StatusOK, echo.Map{
+		"items":     publicMembers,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
 }
 
 // MCP: 根据 cn 获取成员信息（需要成员权限；查询无 cn 限制）
@@ -60,7 +205,7 @@ func GetClubMemberByCN(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
 	}
 
-	return c.JSON(http.StatusOK, toClubMemberPublic(member))
+	return c.JSON(http.StatusOK, toClubMemberPublic(member, newPrivacyView(c, member.CN)))
 }
 
 // MCP: 更新自己的成员信息（除 password 外）
@@ -70,7 +215,7 @@ func UpdateClubMemberByCN(c echo.Context) error {
 	if actorCN == "" {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "未提供认证token"})
 	}
-	if actorCN != targetCN && !isMCPAdminCN(actorCN) {
+	if actorCN != targetCN && !hasPermission(c, PermMembersWrite) {
 		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权修改他人信息"})
 	}
 
@@ -94,6 +239,14 @@ func UpdateClubMemberByCN(c echo.Context) error {
 	if result.Error != nil {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
 	}
+	if err := normalizeMemberInput(memberVocabFields{Sex: req.Sex, Position: req.Position, Year: req.Year, Direction: req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	// 组织架构职位受名额限制并留有任职记录，成员本人不能直接改为或改离这些职位
+	if req.Position != nil && *req.Position != member.Position && !hasPermission(c, PermMembersWrite) &&
+		(isOrgPositionName(*req.Position) || isOrgPositionName(member.Position)) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "组织架构中的职位请由干部通过任命接口调整"})
+	}
 
 	updates := map[string]interface{}{}
 	if req.Sex != nil {
@@ -109,10 +262,10 @@ func UpdateClubMemberByCN(c echo.Context) error {
 		updates["direction"] = *req.Direction
 	}
 	if req.Status != nil {
-		updates["status"] = *req.Status
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "在役状态请通过状态变更接口修改"})
 	}
 	if req.IsMember != nil {
-		updates["is_member"] = *req.IsMember
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "社团成员身份请通过入社申请审核修改"})
 	}
 	if req.Remark != nil {
 		updates["remark"] = *req.Remark
@@ -122,13 +275,15 @@ func UpdateClubMemberByCN(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "未提供可更新字段"})
 	}
 
+	before := toClubMemberPublic(member, nil)
 	if err := config.DB.Model(&member).Updates(updates).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "更新失败"})
 	}
 
 	// reload
 	_ = config.DB.Where("cn = ?", targetCN).First(&member).Error
-	return c.JSON(http.StatusOK, toClubMemberPublic(member))
+	recordAudit(c, AuditMemberUpdate, "member", targetCN, before, toClubMemberPublic(member, nil))
+	return c.JSON(http.StatusOK, toClubMemberPublic(member, nil))
 }
 
 // MCP: 删除自己的成员信息
@@ -138,18 +293,16 @@ func DeleteClubMemberByCN(c echo.Context) error {
 	if actorCN == "" {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "未提供认证token"})
 	}
-	if actorCN != targetCN && !isMCPAdminCN(actorCN) {
+	if actorCN != targetCN && !hasPermission(c, PermMembersWrite) {
 		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权删除他人信息"})
 	}
 
-	result := config.DB.Where("cn = ?", targetCN).Delete(&models.ClubMember{})
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
-	}
-	if result.RowsAffected == 0 {
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", targetCN).First(&member).Error; err != nil {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
 	}
-	return c.NoContent(http.StatusNoContent)
+
+	return respondMemberDeletion(c, member)
 }
 
 // 新增社团成员
@@ -168,9 +321,9 @@ func CreateClubMember(c echo.Context) error {
 // 根据ID删除社团成员
 func DeleteClubMember(c echo.Context) error {
 	id := c.Param("id")
-	result := config.DB.Delete(&models.ClubMember{}, id)
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	var member models.ClubMember
+	if err := config.DB.First(&member, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
 	}
-	return c.NoContent(http.StatusNoContent)
+	return respondMemberDeletion(c, member)
 }
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func updateMember(t *testing.T, cn, body, actor string, perms ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("cn")
+	c.SetParamValues(cn)
+	c.Set("user_cn", actor)
+	c.Set("permissions", perms)
+	if err := UpdateClubMemberByCN(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestUpdateMemberRejectsMembershipChange(t *testing.T) {
+	newTestDB(t)
+	newTestMember(t, "guest", false)
+	if err := config.DB.Model(&models.ClubMember{}).Where("cn = ?", "guest").Update("status", models.MemberStatusApplicant).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.MembershipApplication{CN: "guest", Status: ApplicationPending}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 干部也不能绕过审核直接修改成员身份
+	rec := updateMember(t, "guest", `{"is_member":true}`, "officer", PermMembersWrite)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("is_member update: %d %s", rec.Code, rec.Body)
+	}
+	var m models.ClubMember
+	config.DB.Where("cn = ?", "guest").First(&m)
+	if m.IsMember || m.Status != models.MemberStatusApplicant {
+		t.Fatalf("guest after update: is_member=%v status=%q", m.IsMember, m.Status)
+	}
+}
+
+func TestUpdateMemberSelfCannotTakeOrgPosition(t *testing.T) {
+	newTestDB(t)
+	if err := SeedVocabularies(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.OrgPosition{Name: "社长", SeatLimit: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	newTestMember(t, "alice", true)
+
+	rec := updateMember(t, "alice", `{"position":"社长"}`, "alice", PermProfileWrite)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("self-assigned org position: %d %s", rec.Code, rec.Body)
+	}
+	// 普通职务仍可自行修改
+	rec = updateMember(t, "alice", `{"position":"组长"}`, "alice", PermProfileWrite)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("self-edit plain position: %d %s", rec.Code, rec.Body)
+	}
+	rec = updateMember(t, "alice", `{"position":"社长"}`, "officer", PermMembersWrite)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("officer edit: %d %s", rec.Code, rec.Body)
+	}
+	// 担任组织架构职位的成员也不能自行改离
+	rec = updateMember(t, "alice", `{"position":"组员"}`, "alice", PermProfileWrite)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("self-removed org position: %d %s", rec.Code, rec.Body)
+	}
+}
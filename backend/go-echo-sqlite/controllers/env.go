@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDuration 读取时长配置（如 15m、24h），未设置或格式错误时返回默认值
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv(name))); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// envInt 读取正整数配置，未设置或格式错误时返回默认值
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name))); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// envBool 读取开关配置（true/1 为开启）
+func envBool(name string) bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	return v == "true" || v == "1"
+}
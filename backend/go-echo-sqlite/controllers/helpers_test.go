@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestDB 为每个测试初始化独立的临时数据库，并清空缓存的签名密钥
+func newTestDB(t *testing.T) {
+	t.Helper()
+	config.DBName = filepath.Join(t.TempDir(), "test.db")
+	config.InitDB()
+	jwtKeys = &keyring{}
+}
+
+// newTestMember 创建成员并按需分配角色
+func newTestMember(t *testing.T, cn string, isMember bool, roles ...string) models.ClubMember {
+	t.Helper()
+	member := models.ClubMember{CN: cn, Password: "x", IsMember: true}
+	if err := config.DB.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !isMember {
+		member.IsMember = false
+		if err := config.DB.Model(&member).Update("is_member", false).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, role := range roles {
+		if err := config.DB.Create(&models.MemberRole{CN: cn, RoleName: role}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return member
+}
+
+// newTestSession 为成员创建登录会话，返回访问 token 与刷新令牌
+func newTestSession(t *testing.T, member models.ClubMember, mfa bool) (string, string) {
+	t.Helper()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+	access, refresh, err := startSession(c, member, mfa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return access, refresh
+}
+
+// callAuthorized 携带访问 token 调用经过中间件包装的处理函数
+func callAuthorized(h echo.HandlerFunc, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h(echo.New().NewContext(req, rec))
+	return rec
+}
+
+// newTestContext 构造带路径参数 cn 的请求上下文，set 中的值写入 context（如 user_cn）
+func newTestContext(cn string, set map[string]interface{}) (echo.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.SetParamNames("cn")
+	c.SetParamValues(cn)
+	for k, v := range set {
+		c.Set(k, v)
+	}
+	return c, rec
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 邀请码配置：
+//   INVITATION_TTL       未指定有效期时的默认有效期，默认 168h（7 天）
+//   INVITATION_MAX_USES  单个邀请码允许的最大使用次数上限，默认 200
+
+func invitationTTL() time.Duration { return envDuration("INVITATION_TTL", 7*24*time.Hour) }
+func invitationMaxUses() int       { return envInt("INVITATION_MAX_USES", 200) }
+
+var errInvitationInvalid = errors.New("邀请码无效、已过期或已用完")
+
+// generateInvitationCode 生成形如 ABCD-EFGH-JKLM-NPQR 的邀请码，便于口头或纸面转交
+func generateInvitationCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := totpEncoding.EncodeToString(b)
+	groups := make([]string, 0, 4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeInvitationCode 忽略大小写、空格与分隔符
+func normalizeInvitationCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))
+}
+
+func invitationStatus(inv models.InvitationCode) string {
+	switch {
+	case inv.RevokedAt != nil:
+		return "revoked"
+	case time.Now().After(inv.ExpiresAt):
+		return "expired"
+	case inv.UsedCount >= inv.MaxUses:
+		return "exhausted"
+	default:
+		return "active"
+	}
+}
+
+func invitationResponse(inv models.InvitationCode) echo.Map {
+	return echo.Map{
+		"id":         inv.ID,
+		"hint":       inv.Hint,
+		"max_uses":   inv.MaxUses,
+		"used_count": inv.UsedCount,
+		"expires_at": inv.ExpiresAt,
+		"year":       inv.Year,
+		"direction":  inv.Direction,
+		"role":       inv.Role,
+		"note":       inv.Note,
+		"created_by": inv.CreatedBy,
+		"created_at": inv.CreatedAt,
+		"revoked_at": inv.RevokedAt,
+		"status":     invitationStatus(inv),
+	}
+}
+
+// redeemInvitation 在事务内校验并占用一次邀请码
+func redeemInvitation(tx *gorm.DB, code string) (models.InvitationCode, error) {
+	var inv models.InvitationCode
+	if err := tx.Where("code_hash = ?", hashToken(normalizeInvitationCode(code))).First(&inv).Error; err != nil {
+		return inv, errInvitationInvalid
+	}
+	if invitationStatus(inv) != "active" {
+		return inv, errInvitationInvalid
+	}
+
+	// 以剩余次数为条件自增，避免并发注册超用
+	result := tx.Model(&models.InvitationCode{}).
+		Where("id = ? AND used_count < max_uses AND revoked_at IS NULL AND expires_at > ?", inv.ID, time.Now()).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return inv, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return inv, errInvitationInvalid
+	}
+	inv.UsedCount++
+	return inv, nil
+}
+
+// CreateInvitation 干部生成邀请码
+func CreateInvitation(c echo.Context) error {
+	type CreateRequest struct {
+		MaxUses   int    `json:"max_uses"`
+		ExpiresIn string `json:"expires_in"` // 如 72h，缺省使用 INVITATION_TTL
+		Year      string `json:"year"`
+		Direction string `json:"direction"`
+		Role      string `json:"role"`
+		Note      string `json:"note"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req CreateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	if err := normalizeMemberInput(memberVocabFields{Year: &req.Year, Direction: &req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 || req.MaxUses > invitationMaxUses() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "使用次数必须在 1 到 " + strconv.Itoa(invitationMaxUses()) + " 之间"})
+	}
+
+	ttl := invitationTTL()
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "有效期格式错误"})
+		}
+		ttl = d
+	}
+
+	if req.Role != "" {
+		if req.Role == RoleGuest {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "邀请码不能分配访客角色"})
+		}
+		var role models.Role
+		if err := config.DB.Where("name = ?", req.Role).First(&role).Error; err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "角色不存在"})
+		}
+		// 普通成员角色之外的角色只能由可分配角色的人写入邀请码
+		if req.Role != RoleMember && !hasPermission(c, PermRolesManage) {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "无权通过邀请码分配该角色"})
+		}
+	}
+
+	code, err := generateInvitationCode()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成邀请码失败"})
+	}
+
+	inv := models.InvitationCode{
+		CodeHash:  hashToken(normalizeInvitationCode(code)),
+		Hint:      code[:4],
+		MaxUses:   req.MaxUses,
+		ExpiresAt: time.Now().Add(ttl),
+		Year:      req.Year,
+		Direction: req.Direction,
+		Role:      req.Role,
+		Note:      req.Note,
+		CreatedBy: actorCN,
+	}
+	if err := config.DB.Create(&inv).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成邀请码失败"})
+	}
+	recordAudit(c, AuditInvitationCreate, "invitation", strconv.Itoa(int(inv.ID)), nil, invitationResponse(inv))
+
+	resp := invitationResponse(inv)
+	resp["code"] = code
+	resp["message"] = "邀请码已生成，明文只显示一次"
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListInvitations 列出邀请码，?status=active|expired|exhausted|revoked 过滤
+func ListInvitations(c echo.Context) error {
+	var invitations []models.InvitationCode
+	if err := config.DB.Order("created_at desc").Find(&invitations).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	status := c.QueryParam("status")
+	items := make([]echo.Map, 0, len(invitations))
+	for _, inv := range invitations {
+		if status != "" && invitationStatus(inv) != status {
+			continue
+		}
+		items = append(items, invitationResponse(inv))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// RevokeInvitation 作废邀请码
+func RevokeInvitation(c echo.Context) error {
+	id := c.Param("id")
+
+	var inv models.InvitationCode
+	if err := config.DB.First(&inv, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "邀请码不存在"})
+	}
+	if inv.RevokedAt != nil {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "邀请码已作废"})
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(&inv).Update("revoked_at", &now).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "作废邀请码失败"})
+	}
+	recordAudit(c, AuditInvitationRevoke, "invitation", id, echo.Map{"revoked_at": nil}, echo.Map{"revoked_at": now})
+	return c.NoContent(http.StatusNoContent)
+}
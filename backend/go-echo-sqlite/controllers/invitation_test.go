@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupInvitationTest(t *testing.T) {
+	t.Helper()
+	setupRBACTest(t)
+	t.Setenv("BCRYPT_COST", "4")
+	if err := SeedVocabularies(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createInvitation(t *testing.T, body string, perms ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_cn", "officer")
+	c.Set("permissions", perms)
+	if err := CreateInvitation(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func invitationCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create invitation: %d %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body.Code
+}
+
+func registerWithCode(cn, code string) *httptest.ResponseRecorder {
+	body := `{"cn":"` + cn + `","password":"Sup3rSecret!","year":"2020","invitation_code":"` + code + `"}`
+	return serve(Register, http.MethodPost, "/api/register", body, nil, nil)
+}
+
+func TestInvitationRedeemsIntoMember(t *testing.T) {
+	setupInvitationTest(t)
+	code := invitationCode(t, createInvitation(t, `{"max_uses":1,"year":"2024","direction":"动画"}`, PermInvitationsManage))
+
+	// 大小写与分隔符不影响兑换
+	rec := registerWithCode("alice", strings.ToLower(strings.ReplaceAll(code, "-", " ")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register with code: %d %s", rec.Code, rec.Body)
+	}
+	var m models.ClubMember
+	config.DB.Where("cn = ?", "alice").First(&m)
+	if !m.IsMember || m.Status != models.MemberStatusActive || m.Year != "2024" || m.Direction != "动画" {
+		t.Fatalf("redeemed member: %+v", m)
+	}
+	var pending int64
+	config.DB.Model(&models.MembershipApplication{}).Where("cn = ?", "alice").Count(&pending)
+	if pending != 0 {
+		t.Fatal("invited member landed in the approval queue")
+	}
+
+	// 单次邀请码用完后失效
+	if rec := registerWithCode("bob", code); rec.Code != http.StatusBadRequest {
+		t.Fatalf("exhausted code: %d %s", rec.Code, rec.Body)
+	}
+	var count int64
+	config.DB.Model(&models.ClubMember{}).Where("cn = ?", "bob").Count(&count)
+	if count != 0 {
+		t.Fatal("member created with an exhausted code")
+	}
+}
+
+func TestInvitationRejectsRevokedAndExpiredCodes(t *testing.T) {
+	setupInvitationTest(t)
+	revoked := invitationCode(t, createInvitation(t, `{"max_uses":5}`, PermInvitationsManage))
+	expired := invitationCode(t, createInvitation(t, `{"max_uses":5}`, PermInvitationsManage))
+
+	var inv models.InvitationCode
+	config.DB.Where("code_hash = ?", hashToken(normalizeInvitationCode(revoked))).First(&inv)
+	c, rec := newTestContext("", map[string]interface{}{"user_cn": "officer"})
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(int(inv.ID)))
+	if err := RevokeInvitation(c); err != nil || rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: %d %s", rec.Code, rec.Body)
+	}
+	config.DB.Model(&models.InvitationCode{}).Where("code_hash = ?", hashToken(normalizeInvitationCode(expired))).
+		Update("expires_at", time.Now().Add(-time.Minute))
+
+	for name, code := range map[string]string{"revoked": revoked, "expired": expired, "unknown": "AAAA-BBBB-CCCC-DDDD"} {
+		if rec := registerWithCode("eve", code); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s code: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	var used int64
+	config.DB.Model(&models.InvitationCode{}).Where("used_count > 0").Count(&used)
+	if used != 0 {
+		t.Fatal("rejected redemption consumed a use")
+	}
+}
+
+func TestInvitationRoleRequiresRolesManage(t *testing.T) {
+	setupInvitationTest(t)
+	if rec := createInvitation(t, `{"role":"admin"}`, PermInvitationsManage); rec.Code != http.StatusForbidden {
+		t.Fatalf("officer created an admin invitation: %d %s", rec.Code, rec.Body)
+	}
+	if rec := createInvitation(t, `{"role":"guest"}`, PermInvitationsManage, PermRolesManage); rec.Code != http.StatusBadRequest {
+		t.Fatalf("guest invitation: %d %s", rec.Code, rec.Body)
+	}
+
+	code := invitationCode(t, createInvitation(t, `{"role":"officer"}`, PermInvitationsManage, PermRolesManage))
+	if rec := registerWithCode("carol", code); rec.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", rec.Code, rec.Body)
+	}
+	roles, err := memberRoles(models.ClubMember{CN: "carol", IsMember: true})
+	if err != nil || len(roles) != 1 || roles[0] != RoleOfficer {
+		t.Fatalf("invited roles: %v %v", roles, err)
+	}
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// JWT 签名密钥来源：
+//   - 环境变量 JWT_SIGNING_KEYS：逗号分隔的 kid:secret 列表，第一个为签名密钥，其余仅用于验证
+//   - 数据库 signing_keys 表：通过轮换接口/命令生成，优先于环境变量中的签名密钥
+//
+// 两者都没有时自动生成一个随机密钥写入数据库，多个实例同时启动时都以最新的一条为准；
+// 之后再配置 JWT_SIGNING_KEYS，自动生成的密钥会转为 retiring，改用配置的密钥签名。
+//
+// 轮换后旧密钥进入 retiring 状态，在 JWT_KEY_RETIRE_AFTER（默认与访问 token 有效期一致）内仍可验证。
+// 运行中的服务每隔 JWT_KEY_RELOAD_INTERVAL（默认 1m）重新读取 signing_keys，遇到未知 kid 时也会立即重读，
+// 因此通过 -rotate-jwt-key 或其他实例轮换的密钥无需重启即可生效。
+
+const (
+	signingKeyActive   = "active"
+	signingKeyRetiring = "retiring"
+)
+
+type signingKey struct {
+	kid      string
+	secret   []byte
+	retireAt time.Time // 零值表示不过期
+}
+
+type keyring struct {
+	mu       sync.RWMutex
+	loadedAt time.Time // 零值表示尚未加载
+	missedAt time.Time // 上次因未知 kid 重读的时间
+	active   *signingKey
+	keys     map[string]*signingKey
+}
+
+// 遇到未知 kid 时重读数据库的最小间隔，避免伪造的 kid 造成频繁查询
+const unknownKIDReloadInterval = 5 * time.Second
+
+var jwtKeys = &keyring{}
+
+// parseConfigKeys 解析 JWT_SIGNING_KEYS
+func parseConfigKeys() ([]*signingKey, error) {
+	raw := strings.TrimSpace(os.Getenv("JWT_SIGNING_KEYS"))
+	if raw == "" {
+		return nil, nil
+	}
+
+	var keys []*signingKey
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(part, ":")
+		kid = strings.TrimSpace(kid)
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("JWT_SIGNING_KEYS 格式错误，应为 kid:secret")
+		}
+		keys = append(keys, &signingKey{kid: kid, secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+func keyRetireAfter() time.Duration {
+	return envDuration("JWT_KEY_RETIRE_AFTER", accessTokenTTL())
+}
+
+func keyReloadInterval() time.Duration {
+	return envDuration("JWT_KEY_RELOAD_INTERVAL", time.Minute)
+}
+
+func generateKeyMaterial() (kid string, secret string, err error) {
+	kidBytes := make([]byte, 4)
+	if _, err = rand.Read(kidBytes); err != nil {
+		return "", "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+	kid = time.Now().Format("20060102") + "-" + hex.EncodeToString(kidBytes)
+	return kid, base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+// reload 从配置和数据库重新构建密钥集合，调用方需持有写锁
+func (k *keyring) reload() error {
+	configKeys, err := parseConfigKeys()
+	if err != nil {
+		return err
+	}
+
+	// 配置了签名密钥后，自动生成的密钥不再用于签名
+	if len(configKeys) > 0 {
+		result := config.DB.Model(&models.SigningKey{}).
+			Where("status = ? AND auto_generated = ?", signingKeyActive, true).
+			Updates(map[string]interface{}{"status": signingKeyRetiring, "retire_at": time.Now().Add(keyRetireAfter())})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			fmt.Printf("⚠ 已配置 JWT_SIGNING_KEYS，自动生成的JWT签名密钥转为 retiring，将在 %s 后失效\n", keyRetireAfter())
+		}
+	}
+
+	var stored []models.SigningKey
+	if err := config.DB.Where("status IN ?", []string{signingKeyActive, signingKeyRetiring}).
+		Order("created_at desc, id desc").Find(&stored).Error; err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey)
+	for _, key := range configKeys {
+		keys[key.kid] = key
+	}
+
+	var active *signingKey
+	for _, row := range stored {
+		// secret 为空的记录用于标记配置中的密钥已被轮换下线
+		if row.Secret == "" {
+			if key, ok := keys[row.KID]; ok && row.RetireAt != nil {
+				key.retireAt = *row.RetireAt
+			}
+			continue
+		}
+		key := &signingKey{kid: row.KID, secret: []byte(row.Secret)}
+		if row.RetireAt != nil {
+			key.retireAt = *row.RetireAt
+		}
+		keys[key.kid] = key
+		if row.Status == signingKeyActive && active == nil {
+			active = key
+		}
+	}
+	if active == nil {
+		for _, key := range configKeys {
+			if key.retireAt.IsZero() {
+				active = key
+				break
+			}
+		}
+	}
+
+	// 既没有配置也没有已轮换的密钥时，生成一个随机密钥，避免回退到写死的密钥。
+	// 写入后重新加载：其他实例同时生成的密钥也在表中，各实例都选用最新的一条
+	if active == nil {
+		kid, secret, err := generateKeyMaterial()
+		if err != nil {
+			return err
+		}
+		row := models.SigningKey{KID: kid, Secret: secret, Status: signingKeyActive, AutoGenerated: true}
+		if err := config.DB.Create(&row).Error; err != nil {
+			return err
+		}
+		return k.reload()
+	}
+
+	k.keys = keys
+	k.active = active
+	k.loadedAt = time.Now()
+	return nil
+}
+
+// refresh 距上次加载超过 maxAge 时重新加载；已有密钥时重读失败只记录日志，继续使用现有密钥
+func (k *keyring) refresh(maxAge time.Duration) error {
+	k.mu.RLock()
+	fresh := !k.loadedAt.IsZero() && time.Since(k.loadedAt) < maxAge
+	k.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.loadedAt.IsZero() && time.Since(k.loadedAt) < maxAge {
+		return nil
+	}
+	if err := k.reload(); err != nil {
+		if k.active == nil {
+			return err
+		}
+		fmt.Printf("重新加载JWT签名密钥失败，继续使用现有密钥: %v\n", err)
+		k.loadedAt = time.Now()
+	}
+	return nil
+}
+
+func (k *keyring) ensureLoaded() error {
+	return k.refresh(keyReloadInterval())
+}
+
+// reloadForKID 未知 kid 可能是其他进程刚轮换出的新密钥，重读一次后再查找
+func (k *keyring) reloadForKID(kid string) (*signingKey, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[kid]; ok {
+		return key, true
+	}
+	if time.Since(k.missedAt) < unknownKIDReloadInterval {
+		return nil, false
+	}
+	k.missedAt = time.Now()
+	if err := k.reload(); err != nil {
+		fmt.Printf("重新加载JWT签名密钥失败，继续使用现有密钥: %v\n", err)
+		return nil, false
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// signing 返回当前签名密钥
+func (k *keyring) signing() (*signingKey, error) {
+	if err := k.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active, nil
+}
+
+// verifying 按 kid 返回可用于验证的密钥（active 或未过期的 retiring）
+func (k *keyring) verifying(kid string) ([]byte, bool) {
+	if err := k.ensureLoaded(); err != nil {
+		return nil, false
+	}
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	k.mu.RUnlock()
+	if !ok {
+		if key, ok = k.reloadForKID(kid); !ok {
+			return nil, false
+		}
+	}
+	if !key.retireAt.IsZero() && time.Now().After(key.retireAt) {
+		return nil, false
+	}
+	return key.secret, true
+}
+
+// signToken 使用当前签名密钥签发 token，并在头部写入 kid
+func signToken(claims jwt.Claims) (string, error) {
+	key, err := jwtKeys.signing()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.secret)
+}
+
+// parseToken 根据 kid 选择密钥验证 token
+func parseToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token 缺少 kid")
+		}
+		secret, ok := jwtKeys.verifying(kid)
+		if !ok {
+			return nil, fmt.Errorf("未知或已失效的 kid: %s", kid)
+		}
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+}
+
+// RotateSigningKey 生成新的签名密钥，原签名密钥转为 retiring 并在宽限期后失效；
+// 返回新密钥及原签名密钥保存的失效时间（没有原签名密钥时为 nil）
+func RotateSigningKey() (*models.SigningKey, *time.Time, error) {
+	// 以数据库中的最新状态为准，其他进程可能刚轮换过
+	if err := jwtKeys.refresh(0); err != nil {
+		return nil, nil, err
+	}
+
+	kid, secret, err := generateKeyMaterial()
+	if err != nil {
+		return nil, nil, err
+	}
+	retireAt := time.Now().Add(keyRetireAfter())
+	newKey := models.SigningKey{KID: kid, Secret: secret, Status: signingKeyActive}
+
+	jwtKeys.mu.Lock()
+	defer jwtKeys.mu.Unlock()
+
+	prev := jwtKeys.active
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 清理已过期的旧密钥（保留配置密钥的下线标记）
+		if err := tx.Unscoped().Where("status = ? AND secret <> '' AND retire_at < ?", signingKeyRetiring, time.Now()).
+			Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.SigningKey{}).Where("status = ?", signingKeyActive).
+			Updates(map[string]interface{}{"status": signingKeyRetiring, "retire_at": retireAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 当前签名密钥来自配置时，记录其下线时间
+		if result.RowsAffected == 0 && prev != nil {
+			marker := models.SigningKey{KID: prev.kid, Status: signingKeyRetiring, RetireAt: &retireAt}
+			if err := tx.Unscoped().Where("kid = ?", prev.kid).Delete(&models.SigningKey{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&marker).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&newKey).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := jwtKeys.reload(); err != nil {
+		return nil, nil, err
+	}
+	var previousRetireAt *time.Time
+	if prev != nil {
+		if key, ok := jwtKeys.keys[prev.kid]; ok && !key.retireAt.IsZero() {
+			t := key.retireAt
+			previousRetireAt = &t
+		}
+	}
+	return &newKey, previousRetireAt, nil
+}
+
+// RotateJWTKey 管理员触发签名密钥轮换
+func RotateJWTKey(c echo.Context) error {
+	key, previousRetireAt, err := RotateSigningKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "密钥轮换失败"})
+	}
+	recordAudit(c, AuditKeyRotate, "jwt_key", key.KID, nil, echo.Map{"kid": key.KID})
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":            "密钥轮换成功",
+		"kid":                key.KID,
+		"previous_retire_at": previousRetireAt,
+	})
+}
+
+// ListJWTKeys 列出当前可用的签名密钥（不返回密钥内容）
+func ListJWTKeys(c echo.Context) error {
+	if err := jwtKeys.ensureLoaded(); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "加载签名密钥失败"})
+	}
+
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	keys := make([]echo.Map, 0, len(jwtKeys.keys))
+	for kid, key := range jwtKeys.keys {
+		item := echo.Map{"kid": kid, "active": key == jwtKeys.active}
+		if !key.retireAt.IsZero() {
+			item["retire_at"] = key.retireAt
+			item["expired"] = time.Now().After(key.retireAt)
+		}
+		keys = append(keys, item)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"keys": keys})
+}
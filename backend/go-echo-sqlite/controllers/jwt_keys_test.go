@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func setupJWTKeyTest(t *testing.T) {
+	t.Helper()
+	newTestDB(t)
+	t.Setenv("JWT_SIGNING_KEYS", "")
+}
+
+// rotateElsewhere 模拟其他进程（如 -rotate-jwt-key）直接在数据库中轮换密钥
+func rotateElsewhere(t *testing.T, kid, secret string) {
+	t.Helper()
+	retireAt := time.Now().Add(time.Hour)
+	config.DB.Model(&models.SigningKey{}).Where("status = ?", signingKeyActive).
+		Updates(map[string]interface{}{"status": signingKeyRetiring, "retire_at": retireAt})
+	if err := config.DB.Create(&models.SigningKey{KID: kid, Secret: secret, Status: signingKeyActive}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyringPicksUpKeysRotatedElsewhere(t *testing.T) {
+	setupJWTKeyTest(t)
+	t.Setenv("JWT_KEY_RELOAD_INTERVAL", "1h")
+	old, err := signToken(jwt.RegisteredClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotateElsewhere(t, "other", "other-secret")
+
+	// 其他实例用新密钥签发的 token 立即可以验证
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "alice"})
+	token.Header["kid"] = "other"
+	fresh, _ := token.SignedString([]byte("other-secret"))
+	if _, err := parseToken(fresh, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token signed with the new key rejected: %v", err)
+	}
+	if _, err := parseToken(old, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token signed with the retiring key rejected: %v", err)
+	}
+
+	// 超过重读间隔后改用新密钥签发
+	t.Setenv("JWT_KEY_RELOAD_INTERVAL", "1ns")
+	signed, err := signToken(jwt.RegisteredClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+	if parsed.Header["kid"] != "other" {
+		t.Fatalf("signed with kid %v after reload", parsed.Header["kid"])
+	}
+}
+
+func TestRotateJWTKeyReturnsStoredRetireTime(t *testing.T) {
+	setupJWTKeyTest(t)
+	prev, err := jwtKeys.signing()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	c.Set("user_cn", "admin")
+	if err := RotateJWTKey(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotate: %d %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		PreviousRetireAt *time.Time `json:"previous_retire_at"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	var row models.SigningKey
+	config.DB.Where("kid = ?", prev.kid).First(&row)
+	if resp.PreviousRetireAt == nil || row.RetireAt == nil || !resp.PreviousRetireAt.Equal(*row.RetireAt) {
+		t.Fatalf("previous_retire_at = %v, stored %v", resp.PreviousRetireAt, row.RetireAt)
+	}
+}
+
+func TestConfiguredKeysReplaceGeneratedKey(t *testing.T) {
+	setupJWTKeyTest(t)
+	// 首次启动时未配置密钥
+	old, err := signToken(jwt.RegisteredClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_SIGNING_KEYS", "cfg:configured-secret")
+	jwtKeys = &keyring{}
+	signed, err := signToken(jwt.RegisteredClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+	if parsed.Header["kid"] != "cfg" {
+		t.Fatalf("signed with kid %v, want the configured key", parsed.Header["kid"])
+	}
+	// 自动生成的密钥转为 retiring，已签发的 token 在宽限期内仍然有效
+	if _, err := parseToken(old, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token signed with the generated key rejected: %v", err)
+	}
+	var row models.SigningKey
+	config.DB.Where("auto_generated = ?", true).First(&row)
+	if row.Status != signingKeyRetiring || row.RetireAt == nil {
+		t.Fatalf("generated key: status=%s retire_at=%v", row.Status, row.RetireAt)
+	}
+}
+
+func TestInstancesAgreeOnGeneratedKey(t *testing.T) {
+	setupJWTKeyTest(t)
+	first := &keyring{}
+	if err := first.refresh(0); err != nil {
+		t.Fatal(err)
+	}
+	// 另一个实例同时启动，也生成了密钥
+	if err := config.DB.Create(&models.SigningKey{KID: "other", Secret: "other-secret", Status: signingKeyActive, AutoGenerated: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	second := &keyring{}
+	if err := second.refresh(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.refresh(0); err != nil {
+		t.Fatal(err)
+	}
+	if first.active.kid != second.active.kid {
+		t.Fatalf("instances sign with %s and %s", first.active.kid, second.active.kid)
+	}
+	var count int64
+	config.DB.Model(&models.SigningKey{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("%d signing keys generated", count)
+	}
+}
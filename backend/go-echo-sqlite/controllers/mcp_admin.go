@@ -2,37 +2,32 @@ package controllers
 
 import (
 	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
 	"strings"
-	"sync"
 )
 
-var (
-	mcpAdminOnce sync.Once
-	mcpAdminSet  map[string]struct{}
-)
-
-func loadMCPAdminSet() {
+// mcpAdminBootstrapCNs 读取 MCP_ADMIN_CNS，仅用于初始化第一批管理员（见 SeedRBAC）
+func mcpAdminBootstrapCNs() []string {
 	raw := strings.TrimSpace(os.Getenv("MCP_ADMIN_CNS"))
-	if raw == "" {
-		raw = "柠白夜,香煎包,猫德oxo,详见包"
-	}
 
-	m := make(map[string]struct{})
+	var cns []string
 	for _, part := range strings.Split(raw, ",") {
 		cn := strings.TrimSpace(part)
 		if cn == "" {
 			continue
 		}
-		m[cn] = struct{}{}
+		cns = append(cns, cn)
 	}
-	mcpAdminSet = m
+	return cns
 }
 
+// isMCPAdminCN 判断成员是否被分配了管理员角色
 func isMCPAdminCN(cn string) bool {
-	mcpAdminOnce.Do(loadMCPAdminSet)
 	if cn == "" {
 		return false
 	}
-	_, ok := mcpAdminSet[cn]
-	return ok
+	var count int64
+	config.DB.Model(&models.MemberRole{}).Where("cn = ? AND role_name = ?", cn, RoleAdmin).Count(&count)
+	return count > 0
 }
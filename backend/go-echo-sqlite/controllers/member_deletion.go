@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// memberDeletion 一次成员删除的结果，用于审计与响应
+type memberDeletion struct {
+	Member          models.ClubMember
+	Profile         *models.MemberProfile
+	TenuresDeleted  int64
+	SessionsRevoked int64
+	TokensRevoked   int64
+}
+
+// deleteMemberCascade 删除成员及其关联数据，两条删除路由共用。
+// 成员、个人主页、任职记录、角色与外部身份绑定为软删除，可从回收站一并恢复；会话与个人访问令牌直接撤销；
+// 待审核的入社申请关闭。头像移入隔离目录，完成后重新同步成员知识库。
+func deleteMemberCascade(member models.ClubMember) (memberDeletion, error) {
+	del := memberDeletion{Member: member}
+	cn := member.CN
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("cn = ?", cn).Delete(&models.ClubMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var profile models.MemberProfile
+		if err := tx.Where("cn = ?", cn).First(&profile).Error; err == nil {
+			if err := tx.Delete(&profile).Error; err != nil {
+				return err
+			}
+			del.Profile = &profile
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 任职记录随成员进入回收站，不再出现在任职列表、学年名单中，也不再占用职位名额
+		result = tx.Where("cn = ?", cn).Delete(&models.Tenure{})
+		if result.Error != nil {
+			return result.Error
+		}
+		del.TenuresDeleted = result.RowsAffected
+
+		// 角色与外部身份绑定同样随成员进入回收站：不再计入管理员人数，也不会被同名的新账号继承
+		for _, model := range []interface{}{&models.MemberRole{}, &models.ExternalIdentity{}} {
+			if err := tx.Where("cn = ?", cn).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		n, err := revokeSessionsForCN(tx, cn)
+		if err != nil {
+			return err
+		}
+		del.SessionsRevoked = n
+
+		result = tx.Model(&models.AccessToken{}).
+			Where("owner_type = ? AND owner = ? AND revoked_at IS NULL", tokenOwnerMember, cn).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		del.TokensRevoked = result.RowsAffected
+
+		now := time.Now()
+		return tx.Model(&models.MembershipApplication{}).
+			Where("cn = ? AND status = ?", cn, ApplicationPending).
+			Updates(map[string]interface{}{
+				"status":      ApplicationRejected,
+				"reason":      "成员已被删除",
+				"reviewed_by": "system",
+				"reviewed_at": &now,
+			}).Error
+	})
+	if err != nil {
+		return del, err
+	}
+
+	if del.Profile != nil {
+		if err := quarantineAvatar(del.Profile.Avatar); err != nil {
+			fmt.Printf("隔离头像文件失败: %v\n", err)
+		}
+	}
+	syncMembersAsync()
+	return del, nil
+}
+
+// purgeMemberRelations 成员被彻底删除时清理角色、外部身份绑定、任职、状态变更记录与隐私设置
+func purgeMemberRelations(tx *gorm.DB, cn string) error {
+	// 均为彻底删除：这些记录大多已随成员软删除；外部身份与隐私设置的唯一索引包含软删除记录，
+	// 保留会导致该外部账号无法再次绑定、同名成员无法再次注册
+	for _, model := range []interface{}{&models.MemberRole{}, &models.ExternalIdentity{}, &models.StatusChange{}, &models.Tenure{}, &models.MemberPrivacy{}} {
+		if err := tx.Unscoped().Where("cn = ?", cn).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashedMemberRelations 随成员软删除、恢复的关联表
+var trashedMemberRelations = []string{"tenures", "member_roles", "external_identities"}
+
+// TrashRelationsOfDeletedMembers 将回收站中成员仍有效的任职记录、角色与外部身份绑定软删除
+// （早期版本删除成员时未处理这些记录），删除时间与成员相同，恢复成员时一并恢复
+func TrashRelationsOfDeletedMembers() (int64, error) {
+	var total int64
+	for _, table := range trashedMemberRelations {
+		result := config.DB.Exec(fmt.Sprintf(`UPDATE %[1]s SET deleted_at = (
+			SELECT club_members.deleted_at FROM club_members WHERE club_members.cn = %[1]s.cn
+		) WHERE deleted_at IS NULL AND cn IN (SELECT cn FROM club_members WHERE deleted_at IS NOT NULL)`, table))
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
+
+// syncMembersAsync 在后台重新生成成员知识库文件
+func syncMembersAsync() {
+	if ragService == nil {
+		return
+	}
+	go func() {
+		if err := ragService.SyncMembersToMarkdown(); err != nil {
+			fmt.Printf("同步成员知识库失败: %v\n", err)
+		}
+	}()
+}
+
+// respondMemberDeletion 统一的删除响应与审计
+func respondMemberDeletion(c echo.Context, member models.ClubMember) error {
+	del, err := deleteMemberCascade(member)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
+	}
+
+	recordAudit(c, AuditMemberDelete, "member", member.CN, toClubMemberPublic(member, nil), echo.Map{
+		"profile_deleted":  del.Profile != nil,
+		"tenures_deleted":  del.TenuresDeleted,
+		"sessions_revoked": del.SessionsRevoked,
+		"tokens_revoked":   del.TokensRevoked,
+	})
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportColumn 导出文件中的一列；表头与导入时识别的表头一致，导出的文件可直接再导入
+type exportColumn struct {
+	Key    string
+	Header string
+}
+
+var memberExportColumns = []exportColumn{
+	{"cn", "姓名"},
+	{"sex", "性别"},
+	{"position", "职务"},
+	{"year", "入学年份"},
+	{"direction", "方向"},
+	{"status", "在役状态"},
+	{"is_member", "社团成员"},
+	{"remark", "备注"},
+}
+
+var profileExportColumns = []exportColumn{
+	{"bili_uid", "B站UID"},
+	{"signature", "个性签名"},
+	{"representative_work", "代表作"},
+	{"other", "其他信息"},
+}
+
+// exportRecord 将成员（及个人主页）转换为导出的一行，只包含公开字段
+func exportRecord(m models.ClubMember, profile *models.MemberProfile, includeProfile bool) map[string]string {
+	isMember := "否"
+	if m.IsMember {
+		isMember = "是"
+	}
+	record := map[string]string{
+		"cn":        m.CN,
+		"sex":       m.Sex,
+		"position":  m.Position,
+		"year":      m.Year,
+		"direction": m.Direction,
+		"status":    models.MemberStatusLabel(m.Status),
+		"is_member": isMember,
+		"remark":    m.Remark,
+	}
+	if includeProfile {
+		record["bili_uid"], record["signature"], record["representative_work"], record["other"] = "", "", "", ""
+		if profile != nil {
+			record["bili_uid"] = profile.BiliUID
+			record["signature"] = profile.Signature
+			record["representative_work"] = profile.RepresentativeWork
+			record["other"] = profile.Other
+		}
+	}
+	return record
+}
+
+// ExportMembers 导出成员名单，?format=csv|xlsx|json（默认 csv），?include_profile=true 附带个人主页字段；
+// 过滤与排序参数与成员列表相同
+func ExportMembers(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" && format != "json" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format 只能为 csv、xlsx 或 json"})
+	}
+	includeProfile, _ := strconv.ParseBool(c.QueryParam("include_profile"))
+
+	query, err := clubMemberQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	var members []models.ClubMember
+	if err := query.Select("cn", "sex", "position", "year", "direction", "status", "is_member", "remark").Find(&members).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	profiles := make(map[string]*models.MemberProfile)
+	if includeProfile {
+		cns := make([]string, 0, len(members))
+		for _, m := range members {
+			cns = append(cns, m.CN)
+		}
+		var rows []models.MemberProfile
+		config.DB.Where("cn IN ?", cns).Find(&rows)
+		for i := range rows {
+			profiles[rows[i].CN] = &rows[i]
+		}
+	}
+
+	columns := memberExportColumns
+	if includeProfile {
+		columns = append(append([]exportColumn{}, memberExportColumns...), profileExportColumns...)
+	}
+	records := make([]map[string]string, 0, len(members))
+	for _, m := range members {
+		records = append(records, exportRecord(m, profiles[m.CN], includeProfile))
+	}
+
+	recordAudit(c, AuditMemberExport, "member", format, nil, echo.Map{
+		"count":           len(records),
+		"include_profile": includeProfile,
+		"filters":         c.QueryString(),
+	})
+
+	filename := "members_" + time.Now().Format("20060102") + "." + format
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	switch format {
+	case "json":
+		items := make([]map[string]interface{}, 0, len(records))
+		for i, record := range records {
+			item := make(map[string]interface{}, len(record))
+			for k, v := range record {
+				item[k] = v
+			}
+			item["is_member"] = members[i].IsMember
+			item["status"] = members[i].Status
+			item["status_label"] = record["status"]
+			items = append(items, item)
+		}
+		return c.JSON(http.StatusOK, items)
+	case "xlsx":
+		data, err := memberExportXLSX(columns, records)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成 XLSX 失败"})
+		}
+		return c.Blob(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", data)
+	default:
+		data, err := memberExportCSV(columns, records)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成 CSV 失败"})
+		}
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+	}
+}
+
+// csvSafe 为以公式字符开头的单元格加上单引号，防止表格软件把成员填写的内容当作公式执行
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func memberExportCSV(columns []exportColumn, records []map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	// 写入 UTF-8 BOM，Excel 打开时中文不乱码
+	buf.WriteString("\xef\xbb\xbf")
+	w := csv.NewWriter(&buf)
+
+	header := make([]string, 0, len(columns))
+	for _, col := range columns {
+		header = append(header, col.Header)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		row := make([]string, 0, len(columns))
+		for _, col := range columns {
+			row = append(row, csvSafe(record[col.Key]))
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func memberExportXLSX(columns []exportColumn, records []map[string]string) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "成员名单"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		header = append(header, col.Header)
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		row := make([]interface{}, 0, len(columns))
+		for _, col := range columns {
+			row = append(row, record[col.Key])
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,405 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 成员批量导入配置：
+//   MEMBER_IMPORT_MAX_ROWS   单次导入的最大行数，默认 1000
+//   MEMBER_IMPORT_MAX_BYTES  上传文件的最大字节数，默认 5242880（5MB）
+//   MEMBER_IMPORT_WORKERS    并行哈希密码的协程数，默认为 CPU 核数
+
+func memberImportMaxRows() int { return envInt("MEMBER_IMPORT_MAX_ROWS", 1000) }
+
+func memberImportMaxBytes() int64 { return int64(envInt("MEMBER_IMPORT_MAX_BYTES", 5<<20)) }
+
+func memberImportWorkers() int {
+	if n := envInt("MEMBER_IMPORT_WORKERS", runtime.NumCPU()); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// hashPasswords 用有限个协程并行哈希密码，逐个哈希上千行会超出请求超时
+func hashPasswords(passwords []string) ([]string, error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < memberImportWorkers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i], errs[i] = hashPassword(passwords[i])
+			}
+		}()
+	}
+	for i := range passwords {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// generateInitialPassword 生成满足密码策略的初始密码，长度取 PASSWORD_MIN_LENGTH 与 12 中的较大值
+func generateInitialPassword(cn string) (string, error) {
+	length := passwordMinLength()
+	if length < 12 {
+		length = 12
+	}
+	for i := 0; i < 5; i++ {
+		token, err := randomToken(length)
+		if err != nil {
+			return "", err
+		}
+		password := token[:length]
+		if checkPasswordPolicy(cn, password) == nil {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("无法生成满足密码策略的 %d 位初始密码", length)
+}
+
+// importFieldHeaders 未提供 mapping 时按这些表头识别各列
+var importFieldHeaders = map[string][]string{
+	"cn":        {"cn", "姓名", "用户名"},
+	"password":  {"password", "密码"},
+	"sex":       {"sex", "性别"},
+	"year":      {"year", "入学年份", "年级"},
+	"direction": {"direction", "方向"},
+	"position":  {"position", "职务", "职位"},
+	"status":    {"status", "在役状态", "状态"},
+	"remark":    {"remark", "备注"},
+}
+
+// importRow 导入文件中的一行及其校验结果
+type importRow struct {
+	Line     int
+	Fields   map[string]string
+	Password string
+	Errors   []string
+}
+
+// readImportFile 读取 CSV 或 XLSX，返回包含表头在内的所有行
+func readImportFile(name string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		// Excel 导出的 CSV 带有 UTF-8 BOM
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		return r.ReadAll()
+	case ".xlsx":
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("文件中没有工作表")
+		}
+		return f.GetRows(sheets[0])
+	default:
+		return nil, errors.New("只支持 .csv 与 .xlsx 文件")
+	}
+}
+
+// resolveImportColumns 根据 mapping（字段名 -> 表头）或默认表头确定各字段所在列
+func resolveImportColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int)
+	for field, defaults := range importFieldHeaders {
+		candidates := defaults
+		if h, ok := mapping[field]; ok {
+			candidates = []string{h}
+		}
+		for _, h := range candidates {
+			if i, ok := index[strings.ToLower(strings.TrimSpace(h))]; ok {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	for field := range mapping {
+		if _, ok := importFieldHeaders[field]; !ok {
+			return nil, fmt.Errorf("未知字段 %s", field)
+		}
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("表头中找不到 %s 对应的列 %s", field, mapping[field])
+		}
+	}
+	if _, ok := columns["cn"]; !ok {
+		return nil, errors.New("缺少 CN 列")
+	}
+	return columns, nil
+}
+
+// validateImportRows 逐行校验并按词表归一性别、职务、入学年份与方向，错误写入各行的 Errors
+func validateImportRows(rows []*importRow, vocab *vocabulary, generatePasswords bool) {
+	seen := make(map[string]int)
+	cns := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cns = append(cns, row.Fields["cn"])
+	}
+
+	taken := make(map[string]bool)
+	var existing []models.ClubMember
+	config.DB.Unscoped().Select("cn").Where("cn IN ?", cns).Find(&existing)
+	for _, m := range existing {
+		taken[m.CN] = true
+	}
+	var aliases []models.MemberAlias
+	config.DB.Where("old_cn IN ?", cns).Find(&aliases)
+	for _, a := range aliases {
+		taken[a.OldCN] = true
+	}
+
+	for _, row := range rows {
+		cn := row.Fields["cn"]
+		switch {
+		case cn == "":
+			row.Errors = append(row.Errors, "CN 不能为空")
+		case strings.ContainsAny(cn, `/\`):
+			row.Errors = append(row.Errors, "CN 不能包含 / 或 \\")
+		case taken[cn]:
+			row.Errors = append(row.Errors, "CN 已存在")
+		}
+		if first, ok := seen[cn]; ok && cn != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("CN 与第 %d 行重复", first))
+		} else {
+			seen[cn] = row.Line
+		}
+
+		for _, field := range []string{vocabSex, vocabPosition, "year", vocabDirection} {
+			value, err := vocab.normalize(field, row.Fields[field])
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+				continue
+			}
+			row.Fields[field] = value
+		}
+		// 状态可填标准状态或中文名称，未填写时为在役；导入的都是社团成员，不能为申请中
+		if status := row.Fields["status"]; status != "" {
+			s, ok := normalizeMemberStatus(status)
+			if !ok || s == models.MemberStatusApplicant {
+				row.Errors = append(row.Errors, "在役状态无效："+status)
+			} else {
+				row.Fields["status"] = s
+			}
+		}
+
+		switch {
+		case row.Fields["password"] != "":
+			if err := checkPasswordPolicy(cn, row.Fields["password"]); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		case !generatePasswords:
+			row.Errors = append(row.Errors, "缺少密码（可选择自动生成初始密码）")
+		}
+	}
+}
+
+// ImportMembers 通过 CSV/XLSX 批量导入成员
+// 表单字段：file 文件；mapping 可选 JSON，字段名到表头的映射，如 {"cn":"昵称"}；
+// dry_run=true 只校验不写入；generate_passwords=true 为未填密码的行生成初始密码（仅在本次响应中返回）
+func ImportMembers(c echo.Context) error {
+	tooLarge := func() error {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": fmt.Sprintf("文件不能超过 %d 字节", memberImportMaxBytes())})
+	}
+	// 限制整个请求体，额外留出 1MB 给表单的其他字段与分隔符
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, memberImportMaxBytes()+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return tooLarge()
+		}
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请上传文件"})
+	}
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "读取文件失败"})
+	}
+	defer src.Close()
+	if file.Size > memberImportMaxBytes() {
+		return tooLarge()
+	}
+	data, err := io.ReadAll(io.LimitReader(src, memberImportMaxBytes()+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "读取文件失败"})
+	}
+	if int64(len(data)) > memberImportMaxBytes() {
+		return tooLarge()
+	}
+
+	mapping := map[string]string{}
+	if raw := c.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "mapping 格式错误"})
+		}
+	}
+	dryRun, _ := strconv.ParseBool(c.FormValue("dry_run"))
+	generatePasswords, _ := strconv.ParseBool(c.FormValue("generate_passwords"))
+
+	records, err := readImportFile(file.Filename, data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "解析文件失败：" + err.Error()})
+	}
+	if len(records) < 2 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "文件中没有数据行"})
+	}
+	if len(records)-1 > memberImportMaxRows() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": fmt.Sprintf("单次最多导入 %d 行", memberImportMaxRows())})
+	}
+	columns, err := resolveImportColumns(records[0], mapping)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var rows []*importRow
+	for i, record := range records[1:] {
+		row := &importRow{Line: i + 2, Fields: make(map[string]string)}
+		empty := true
+		for field, col := range columns {
+			if col < len(record) {
+				row.Fields[field] = strings.TrimSpace(record[col])
+				if row.Fields[field] != "" {
+					empty = false
+				}
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	vocab, err := loadVocabulary(config.DB, 0)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	validateImportRows(rows, vocab, generatePasswords)
+
+	invalid := 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			invalid++
+		}
+	}
+	report := func() []echo.Map {
+		items := make([]echo.Map, 0, len(rows))
+		for _, row := range rows {
+			errs := row.Errors
+			if errs == nil {
+				errs = []string{}
+			}
+			item := echo.Map{"line": row.Line, "cn": row.Fields["cn"], "errors": errs}
+			if row.Password != "" {
+				item["initial_password"] = row.Password
+			}
+			items = append(items, item)
+		}
+		return items
+	}
+	resp := echo.Map{
+		"dry_run": dryRun,
+		"total":   len(rows),
+		"valid":   len(rows) - invalid,
+		"invalid": invalid,
+	}
+
+	if dryRun || invalid > 0 {
+		resp["rows"] = report()
+		if invalid > 0 && !dryRun {
+			resp["error"] = "存在校验失败的行，未导入任何成员"
+			return c.JSON(http.StatusUnprocessableEntity, resp)
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	// bcrypt 较慢，在事务外生成并哈希密码，避免长时间占用数据库写锁
+	passwords := make([]string, len(rows))
+	for i, row := range rows {
+		passwords[i] = row.Fields["password"]
+		if passwords[i] == "" {
+			generated, err := generateInitialPassword(row.Fields["cn"])
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			}
+			passwords[i], row.Password = generated, generated
+		}
+	}
+	hashes, err := hashPasswords(passwords)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "密码加密失败"})
+	}
+
+	// 所有行在同一事务内写入，任何一行失败都整体回滚
+	actorCN, _ := c.Get("user_cn").(string)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for i, row := range rows {
+			member := models.ClubMember{
+				CN:        row.Fields["cn"],
+				Password:  hashes[i],
+				Sex:       row.Fields["sex"],
+				Position:  row.Fields["position"],
+				Year:      row.Fields["year"],
+				Direction: row.Fields["direction"],
+				Status:    initialMemberStatus(row.Fields["status"], true),
+				Remark:    row.Fields["remark"],
+				IsMember:  true,
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				return fmt.Errorf("第 %d 行写入失败: %w", row.Line, err)
+			}
+			if err := tx.Create(&models.StatusChange{CN: member.CN, ToStatus: member.Status, Reason: "批量导入", ChangedBy: actorCN}).Error; err != nil {
+				return fmt.Errorf("第 %d 行写入失败: %w", row.Line, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "导入失败，未导入任何成员：" + err.Error()})
+	}
+
+	imported := make([]string, 0, len(rows))
+	for _, row := range rows {
+		imported = append(imported, row.Fields["cn"])
+	}
+	recordAudit(c, AuditMemberImport, "member", file.Filename, nil, echo.Map{"count": len(imported), "cns": imported})
+	syncMembersAsync()
+
+	resp["rows"] = report()
+	resp["imported"] = len(imported)
+	resp["message"] = "导入成功"
+	if generatePasswords {
+		resp["message"] = "导入成功，生成的初始密码只显示一次，请妥善转交成员"
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
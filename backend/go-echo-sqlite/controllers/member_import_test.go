@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func postImport(t *testing.T, content string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", "members.csv")
+	part.Write([]byte(content))
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/members/import", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_cn", "admin")
+	if err := ImportMembers(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestGenerateInitialPasswordFollowsPolicy(t *testing.T) {
+	for _, min := range []string{"8", "16", "24"} {
+		t.Setenv("PASSWORD_MIN_LENGTH", min)
+		password, err := generateInitialPassword("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkPasswordPolicy("alice", password); err != nil {
+			t.Errorf("PASSWORD_MIN_LENGTH=%s: %q violates policy: %v", min, password, err)
+		}
+	}
+}
+
+func TestImportMembersGeneratesPolicyCompliantPasswords(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("PASSWORD_MIN_LENGTH", "16")
+	t.Setenv("BCRYPT_COST", "4")
+
+	rec := postImport(t, "cn,year\nalice,2024\nbob,2025\n", map[string]string{"generate_passwords": "true"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import: %d %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Rows []struct {
+			CN              string `json:"cn"`
+			InitialPassword string `json:"initial_password"`
+		} `json:"rows"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Rows) != 2 {
+		t.Fatalf("rows: %s", rec.Body)
+	}
+	for _, row := range resp.Rows {
+		if len(row.InitialPassword) < 16 {
+			t.Errorf("%s: initial password %q shorter than PASSWORD_MIN_LENGTH", row.CN, row.InitialPassword)
+		}
+		var m models.ClubMember
+		config.DB.Where("cn = ?", row.CN).First(&m)
+		if bcrypt.CompareHashAndPassword([]byte(m.Password), []byte(row.InitialPassword)) != nil {
+			t.Errorf("%s: stored hash does not match the returned password", row.CN)
+		}
+	}
+}
+
+func TestImportMembersRejectsOversizedFile(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("MEMBER_IMPORT_MAX_BYTES", "64")
+
+	rec := postImport(t, "cn,password\n"+strings.Repeat("alice,Sup3rSecret!\n", 10), nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized file: %d %s", rec.Code, rec.Body)
+	}
+	var count int64
+	config.DB.Model(&models.ClubMember{}).Count(&count)
+	if count != 0 {
+		t.Fatal("members imported from an oversized file")
+	}
+}
+
+func TestHashPasswordsInParallel(t *testing.T) {
+	t.Setenv("BCRYPT_COST", "4")
+	for _, workers := range []string{"1", "3", "0"} {
+		t.Setenv("MEMBER_IMPORT_WORKERS", workers)
+		passwords := []string{"a1!Passw0rd", "b2!Passw0rd", "c3!Passw0rd", "d4!Passw0rd", "e5!Passw0rd"}
+		hashes, err := hashPasswords(passwords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range passwords {
+			if bcrypt.CompareHashAndPassword([]byte(hashes[i]), []byte(p)) != nil {
+				t.Errorf("workers=%s: hash %d does not match its password", workers, i)
+			}
+		}
+	}
+}
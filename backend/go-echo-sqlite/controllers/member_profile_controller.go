@@ -84,6 +84,15 @@ func handleAvatarUpload(c echo.Context, cn string) (string, error) {
 	return filePath, nil
 }
 
+// canWriteProfile 本人需要 profile:write 权限，修改他人主页需要 members:write 权限
+func canWriteProfile(c echo.Context, cn string) bool {
+	actorCN, _ := c.Get("user_cn").(string)
+	if actorCN == cn {
+		return hasPermission(c, PermProfileWrite)
+	}
+	return hasPermission(c, PermMembersWrite)
+}
+
 // GetMemberProfile 获取成员个人主页信息
 func GetMemberProfile(c echo.Context) error {
 	cn := c.Param("cn")
@@ -94,10 +103,16 @@ func GetMemberProfile(c echo.Context) error {
 	var profile models.MemberProfile
 	result := config.DB.Where("cn = ?", cn).First(&profile)
 	if result.Error != nil {
+		// 成员改过名时跳转到新 CN 的主页
+		if ok, err := redirectMemberAlias(c, cn, ""); ok {
+			return err
+		}
 		// 如果没找到个人主页，返回404
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "未找到该成员的个人主页"})
 	}
 
+	// 按成员的隐私设置隐藏查看者无权看到的字段
+	redactMemberProfile(&profile, newPrivacyView(c, profile.CN))
 	return c.JSON(http.StatusOK, profile)
 }
 
@@ -107,6 +122,9 @@ func CreateOrUpdateMemberProfile(c echo.Context) error {
 	if cn == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "成员姓名不能为空"})
 	}
+	if !canWriteProfile(c, cn) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权修改他人主页"})
+	}
 
 	// 解析multipart表单
 	err := c.Request().ParseMultipartForm(32 << 20) // 32MB max
@@ -159,11 +177,16 @@ func CreateOrUpdateMemberProfile(c echo.Context) error {
 		} else {
 			fmt.Printf("创建模式：没有头像\n")
 		}
+		// 回收站中同名的旧主页会占用唯一索引，新建前将其彻底清除
+		if err := purgeTrashedProfiles(cn); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
 		createResult := config.DB.Create(&profile)
 		if createResult.Error != nil {
 			return c.JSON(http.StatusInternalServerError, echo.Map{"error": createResult.Error.Error()})
 		}
 		fmt.Printf("创建成功，最终profile: %+v\n", profile)
+		recordAudit(c, AuditProfileCreate, "profile", cn, nil, profile)
 		return c.JSON(http.StatusCreated, profile)
 	} else {
 		// 已存在，更新
@@ -190,6 +213,7 @@ func CreateOrUpdateMemberProfile(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, echo.Map{"error": updateResult.Error.Error()})
 		}
 		fmt.Printf("更新成功，最终profile: %+v\n", profile)
+		recordAudit(c, AuditProfileUpdate, "profile", cn, existingProfile, profile)
 		return c.JSON(http.StatusOK, profile)
 	}
 }
@@ -200,8 +224,11 @@ func DeleteMemberProfile(c echo.Context) error {
 	if cn == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "成员姓名不能为空"})
 	}
+	if !canWriteProfile(c, cn) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权修改他人主页"})
+	}
 
-	// 先获取要删除的个人主页信息，以便删除头像文件
+	// 先获取要删除的个人主页信息，以便隔离头像文件
 	var profile models.MemberProfile
 	findResult := config.DB.Where("cn = ?", cn).First(&profile)
 	if findResult.Error != nil {
@@ -214,14 +241,13 @@ func DeleteMemberProfile(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
 	}
 
-	// 删除头像文件
-	if profile.Avatar != "" {
-		if err := os.Remove(profile.Avatar); err != nil {
-			// 记录错误但不影响删除操作
-			fmt.Printf("删除头像文件失败: %v\n", err)
-		}
+	// 头像移入隔离目录，以便从回收站恢复
+	if err := quarantineAvatar(profile.Avatar); err != nil {
+		// 记录错误但不影响删除操作
+		fmt.Printf("隔离头像文件失败: %v\n", err)
 	}
 
+	recordAudit(c, AuditProfileDelete, "profile", cn, profile, nil)
 	return c.NoContent(http.StatusNoContent)
 }
 
@@ -234,6 +260,11 @@ func CheckMemberProfileExists(c echo.Context) error {
 
 	var count int64
 	config.DB.Model(&models.MemberProfile{}).Where("cn = ?", cn).Count(&count)
+	if count == 0 {
+		if ok, err := redirectMemberAlias(c, cn, "/exists"); ok {
+			return err
+		}
+	}
 
 	return c.JSON(http.StatusOK, echo.Map{
 		"exists": count > 0,
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	errRenameTaken = errors.New("用户名已存在")
+	errRenameAlias = errors.New("该用户名是其他成员的曾用名")
+)
+
+// renamedAvatarPath 头像文件名以 CN 开头（<cn>_<ts>.ext），改名时一并替换
+func renamedAvatarPath(avatar, oldCN, newCN string) string {
+	base := filepath.Base(avatar)
+	if avatar == "" || !strings.HasPrefix(base, oldCN+"_") {
+		return avatar
+	}
+	return filepath.Join(filepath.Dir(avatar), newCN+strings.TrimPrefix(base, oldCN))
+}
+
+// renameMember 在事务内将成员及其关联数据迁移到新 CN，并保留旧 CN 的别名。
+// 旧 CN 的会话与访问令牌全部撤销，未使用的备忘码与重置令牌作废。
+func renameMember(oldCN, newCN, actorCN string) (sessions int64, err error) {
+	var oldAvatar, newAvatar string
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Unscoped().Model(&models.ClubMember{}).Where("cn = ?", newCN).Count(&count)
+		if count > 0 {
+			return errRenameTaken
+		}
+
+		// 新 CN 若是自己的曾用名则收回，若是他人的曾用名则拒绝，避免旧链接指向错误的人
+		var alias models.MemberAlias
+		if err := tx.Where("old_cn = ?", newCN).First(&alias).Error; err == nil {
+			if alias.CN != oldCN {
+				return errRenameAlias
+			}
+			if err := tx.Unscoped().Delete(&alias).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&models.ClubMember{}).Where("cn = ?", oldCN).Update("cn", newCN)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		// 个人主页（含回收站中的）随成员改名；有效主页的头像文件同步改名
+		var profile models.MemberProfile
+		if err := tx.Where("cn = ?", oldCN).First(&profile).Error; err == nil {
+			oldAvatar = profile.Avatar
+			newAvatar = renamedAvatarPath(profile.Avatar, oldCN, newCN)
+			if err := tx.Model(&profile).Update("avatar", newAvatar).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Model(&models.MemberProfile{}).Where("cn = ?", oldCN).Update("cn", newCN).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.MemberRole{}, &models.MembershipApplication{}, &models.ExternalIdentity{}, &models.MemberAlias{}, &models.Tenure{}, &models.StatusChange{}, &models.MemberPrivacy{}} {
+			if err := tx.Model(model).Where("cn = ?", oldCN).Update("cn", newCN).Error; err != nil {
+				return err
+			}
+		}
+		// 旧 CN 可能曾是别人的曾用名（该成员改名后又有人注册了它），以最新的改名为准
+		if err := tx.Unscoped().Where("old_cn = ?", oldCN).Delete(&models.MemberAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.MemberAlias{OldCN: oldCN, CN: newCN, RenamedBy: actorCN}).Error; err != nil {
+			return err
+		}
+
+		// 旧凭据全部失效
+		n, err := revokeSessionsForCN(tx, oldCN)
+		if err != nil {
+			return err
+		}
+		sessions = n
+		if err := tx.Model(&models.AccessToken{}).
+			Where("owner_type = ? AND owner = ? AND revoked_at IS NULL", tokenOwnerMember, oldCN).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cn = ?", oldCN).Delete(&models.MemoryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cn = ?", oldCN).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+
+		// 文件改名放在最后，失败时整个事务回滚
+		if newAvatar != oldAvatar {
+			if err := os.Rename(oldAvatar, newAvatar); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && newAvatar != oldAvatar {
+		// 事务失败时把已改名的头像改回去
+		if _, statErr := os.Stat(newAvatar); statErr == nil {
+			_ = os.Rename(newAvatar, oldAvatar)
+		}
+	}
+	return sessions, err
+}
+
+// RenameMember 修改成员 CN：本人或拥有 members:write 权限者可操作
+func RenameMember(c echo.Context) error {
+	type RenameRequest struct {
+		NewCN string `json:"new_cn"`
+	}
+
+	targetCN := c.Param("cn")
+	actorCN, _ := c.Get("user_cn").(string)
+	if actorCN != targetCN && !hasPermission(c, PermMembersWrite) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权修改他人用户名"})
+	}
+
+	var req RenameRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	newCN := strings.TrimSpace(req.NewCN)
+	if newCN == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "新用户名不能为空"})
+	}
+	if strings.ContainsAny(newCN, `/\`) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "用户名不能包含 / 或 \\"})
+	}
+	if newCN == targetCN {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "新用户名与原用户名相同"})
+	}
+
+	sessions, err := renameMember(targetCN, newCN, actorCN)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+		case errors.Is(err, errRenameTaken), errors.Is(err, errRenameAlias):
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		default:
+			fmt.Printf("成员改名失败: %v\n", err)
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "改名失败"})
+		}
+	}
+
+	recordAudit(c, AuditMemberRename, "member", newCN, echo.Map{"cn": targetCN}, echo.Map{"cn": newCN, "sessions_revoked": sessions})
+	syncMembersAsync()
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "改名成功，原有登录状态与访问令牌已失效，请使用新用户名重新登录",
+		"cn":      newCN,
+		"old_cn":  targetCN,
+	})
+}
+
+// isMemberAlias cn 是否为某个成员的曾用名；曾用名不能再注册，否则旧链接会指向新注册的人
+func isMemberAlias(cn string) bool {
+	var count int64
+	config.DB.Model(&models.MemberAlias{}).Where("old_cn = ?", cn).Count(&count)
+	return count > 0
+}
+
+// redirectMemberAlias 旧 CN 的个人主页请求跳转到新 CN；没有别名时返回 false。
+// 曾用名可以被本人收回，使用临时跳转以免浏览器永久缓存
+func redirectMemberAlias(c echo.Context, cn, suffix string) (bool, error) {
+	var alias models.MemberAlias
+	if err := config.DB.Where("old_cn = ?", cn).First(&alias).Error; err != nil {
+		return false, nil
+	}
+	return true, c.Redirect(http.StatusTemporaryRedirect, "/api/member-profile/"+url.PathEscape(alias.CN)+suffix)
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMemberAliasRedirectIsTemporary(t *testing.T) {
+	newTestDB(t)
+	newTestMember(t, "new", true)
+	if err := config.DB.Create(&models.MemberAlias{OldCN: "old", CN: "new"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/member-profile/old", nil), rec)
+	c.SetParamNames("cn")
+	c.SetParamValues("old")
+	if err := GetMemberProfile(c); err != nil {
+		t.Fatal(err)
+	}
+	// 曾用名可以被收回，不能使用会被浏览器永久缓存的 301
+	if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != "/api/member-profile/new" {
+		t.Fatalf("alias redirect: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
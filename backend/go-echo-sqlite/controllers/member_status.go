@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var errStatusChanged = errors.New("成员状态已被其他人修改，请刷新后重试")
+
+// legacyStatusAliases 旧版自由填写的在役状态及常见写法到标准状态的映射
+var legacyStatusAliases = map[string]string{
+	"申请中":   models.MemberStatusApplicant,
+	"在役":    models.MemberStatusActive,
+	"仍然在役":  models.MemberStatusActive,
+	"现役":    models.MemberStatusActive,
+	"暂离":    models.MemberStatusOnLeave,
+	"休假":    models.MemberStatusOnLeave,
+	"请假":    models.MemberStatusOnLeave,
+	"已退居幕后": models.MemberStatusRetired,
+	"退居幕后":  models.MemberStatusRetired,
+	"退役":    models.MemberStatusRetired,
+	"已毕业":   models.MemberStatusGraduated,
+	"毕业":    models.MemberStatusGraduated,
+	"校友":    models.MemberStatusAlumni,
+}
+
+// normalizeMemberStatus 接受标准状态或其中文写法，返回标准状态
+func normalizeMemberStatus(status string) (string, bool) {
+	status = strings.TrimSpace(status)
+	for _, s := range models.MemberStatuses {
+		if strings.EqualFold(status, s) {
+			return s, true
+		}
+	}
+	s, ok := legacyStatusAliases[status]
+	return s, ok
+}
+
+// statusTransition 允许的状态变更：AllowSelf 表示本人可操作，否则需要 Permission；
+// ApprovalOnly 的变更只能通过入社审核完成
+type statusTransition struct {
+	From         string
+	To           string
+	AllowSelf    bool
+	Permission   string
+	ApprovalOnly bool
+}
+
+var statusTransitions = []statusTransition{
+	{models.MemberStatusApplicant, models.MemberStatusActive, false, PermMembersApprove, true},
+	{models.MemberStatusActive, models.MemberStatusOnLeave, true, PermMembersWrite, false},
+	{models.MemberStatusOnLeave, models.MemberStatusActive, true, PermMembersWrite, false},
+	{models.MemberStatusActive, models.MemberStatusRetired, true, PermMembersWrite, false},
+	{models.MemberStatusOnLeave, models.MemberStatusRetired, true, PermMembersWrite, false},
+	{models.MemberStatusRetired, models.MemberStatusActive, false, PermMembersWrite, false},
+	{models.MemberStatusActive, models.MemberStatusGraduated, false, PermMembersWrite, false},
+	{models.MemberStatusOnLeave, models.MemberStatusGraduated, false, PermMembersWrite, false},
+	{models.MemberStatusRetired, models.MemberStatusGraduated, false, PermMembersWrite, false},
+	{models.MemberStatusGraduated, models.MemberStatusAlumni, true, PermMembersWrite, false},
+	{models.MemberStatusRetired, models.MemberStatusAlumni, false, PermMembersWrite, false},
+}
+
+func findStatusTransition(from, to string) (statusTransition, bool) {
+	for _, t := range statusTransitions {
+		if t.From == from && t.To == to {
+			return t, true
+		}
+	}
+	return statusTransition{}, false
+}
+
+// recordStatusChange 在事务内更新成员状态并写入变更记录
+func recordStatusChange(tx *gorm.DB, cn, from, to, reason, actorCN string) error {
+	if err := tx.Model(&models.ClubMember{}).Where("cn = ?", cn).Update("status", to).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.StatusChange{
+		CN:         cn,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		ChangedBy:  actorCN,
+	}).Error
+}
+
+// initialMemberStatus 新建成员时的状态：未填写或无法识别时按是否为社团成员取默认值
+func initialMemberStatus(requested string, isMember bool) string {
+	if !isMember {
+		return models.MemberStatusApplicant
+	}
+	if s, ok := normalizeMemberStatus(requested); ok && s != models.MemberStatusApplicant {
+		return s
+	}
+	return models.MemberStatusActive
+}
+
+// MigrateMemberStatuses 将旧版自由填写的在役状态迁移为标准状态，并为每次迁移留下变更记录
+func MigrateMemberStatuses() error {
+	var members []models.ClubMember
+	if err := config.DB.Select("cn", "status", "is_member").Find(&members).Error; err != nil {
+		return err
+	}
+
+	migrated := 0
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, m := range members {
+			if containsString(models.MemberStatuses, m.Status) {
+				continue
+			}
+			to, ok := normalizeMemberStatus(m.Status)
+			// 访客只能处于申请中，社团成员不能处于申请中
+			if !ok || (to == models.MemberStatusApplicant) == m.IsMember {
+				to = initialMemberStatus("", m.IsMember)
+			}
+			if err := recordStatusChange(tx, m.CN, m.Status, to, "旧状态迁移："+m.Status, "system"); err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	if err == nil && migrated > 0 {
+		fmt.Printf("✓ 已将 %d 名成员的在役状态迁移为标准状态\n", migrated)
+	}
+	return err
+}
+
+func statusChangeResponse(sc models.StatusChange) echo.Map {
+	return echo.Map{
+		"id":         sc.ID,
+		"cn":         sc.CN,
+		"from":       sc.FromStatus,
+		"from_label": models.MemberStatusLabel(sc.FromStatus),
+		"to":         sc.ToStatus,
+		"to_label":   models.MemberStatusLabel(sc.ToStatus),
+		"reason":     sc.Reason,
+		"changed_by": sc.ChangedBy,
+		"changed_at": sc.CreatedAt,
+	}
+}
+
+// ListMemberStatuses 列出全部状态及允许的变更
+func ListMemberStatuses(c echo.Context) error {
+	states := make([]echo.Map, 0, len(models.MemberStatuses))
+	for _, s := range models.MemberStatuses {
+		states = append(states, echo.Map{"status": s, "label": models.MemberStatusLabel(s)})
+	}
+	transitions := make([]echo.Map, 0, len(statusTransitions))
+	for _, t := range statusTransitions {
+		transitions = append(transitions, echo.Map{
+			"from":          t.From,
+			"to":            t.To,
+			"allow_self":    t.AllowSelf,
+			"permission":    t.Permission,
+			"approval_only": t.ApprovalOnly,
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"statuses": states, "transitions": transitions})
+}
+
+// ChangeMemberStatus 按生命周期变更成员状态，需填写原因
+func ChangeMemberStatus(c echo.Context) error {
+	type ChangeRequest struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+
+	targetCN := c.Param("cn")
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req ChangeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	to, ok := normalizeMemberStatus(req.Status)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "未知的状态：" + req.Status})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "变更原因不能为空"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", targetCN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+	from := member.Status
+
+	t, ok := findStatusTransition(from, to)
+	if !ok {
+		return c.JSON(http.StatusConflict, echo.Map{"error": fmt.Sprintf("不允许从「%s」变更为「%s」", models.MemberStatusLabel(from), models.MemberStatusLabel(to))})
+	}
+	if t.ApprovalOnly {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "申请中的成员请通过入社申请审核转为在役"})
+	}
+	if !(t.AllowSelf && actorCN == targetCN) && !hasPermission(c, t.Permission) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权执行该状态变更"})
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 以当前状态为条件，避免并发变更覆盖
+		result := tx.Model(&models.ClubMember{}).Where("cn = ? AND status = ?", targetCN, from).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStatusChanged
+		}
+		return tx.Create(&models.StatusChange{CN: targetCN, FromStatus: from, ToStatus: to, Reason: req.Reason, ChangedBy: actorCN}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errStatusChanged) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "状态变更失败"})
+	}
+
+	recordAudit(c, AuditMemberStatus, "member", targetCN, echo.Map{"status": from}, echo.Map{"status": to, "reason": req.Reason})
+	syncMembersAsync()
+	return c.JSON(http.StatusOK, echo.Map{
+		"cn":           targetCN,
+		"status":       to,
+		"status_label": models.MemberStatusLabel(to),
+		"message":      "状态已更新",
+	})
+}
+
+// GetMemberStatusHistory 成员的状态变更记录，按时间先后排列
+func GetMemberStatusHistory(c echo.Context) error {
+	var changes []models.StatusChange
+	if err := config.DB.Where("cn = ?", c.Param("cn")).Order("created_at asc, id asc").Find(&changes).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	items := make([]echo.Map, 0, len(changes))
+	for _, sc := range changes {
+		items = append(items, statusChangeResponse(sc))
+	}
+	return c.JSON(http.StatusOK, items)
+}
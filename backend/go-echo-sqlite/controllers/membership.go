@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 入社申请状态
+const (
+	ApplicationPending  = "pending"
+	ApplicationApproved = "approved"
+	ApplicationRejected = "rejected"
+)
+
+var errApplicationReviewed = errors.New("该申请已被处理")
+
+func applicationResponse(app models.MembershipApplication, member *models.ClubMember) echo.Map {
+	resp := echo.Map{
+		"id":          app.ID,
+		"cn":          app.CN,
+		"status":      app.Status,
+		"message":     app.Message,
+		"reason":      app.Reason,
+		"reviewed_by": app.ReviewedBy,
+		"reviewed_at": app.ReviewedAt,
+		"created_at":  app.CreatedAt,
+	}
+	if member != nil {
+		resp["sex"] = member.Sex
+		resp["year"] = member.Year
+		resp["direction"] = member.Direction
+		resp["remark"] = member.Remark
+	}
+	return resp
+}
+
+// ListApplications 干部查看入社申请，默认只列出待审核的申请
+func ListApplications(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = ApplicationPending
+	}
+
+	query := config.DB.Order("created_at asc")
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	var apps []models.MembershipApplication
+	if err := query.Find(&apps).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	cns := make([]string, 0, len(apps))
+	for _, app := range apps {
+		cns = append(cns, app.CN)
+	}
+	var members []models.ClubMember
+	config.DB.Where("cn IN ?", cns).Find(&members)
+	byCN := make(map[string]*models.ClubMember, len(members))
+	for i := range members {
+		byCN[members[i].CN] = &members[i]
+	}
+
+	items := make([]echo.Map, 0, len(apps))
+	for _, app := range apps {
+		items = append(items, applicationResponse(app, byCN[app.CN]))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// reviewApplication 在事务内将待审核申请标记为已处理，apply 中执行通过/拒绝的附加操作
+func reviewApplication(c echo.Context, status, reason string, apply func(tx *gorm.DB, app models.MembershipApplication) error) (models.MembershipApplication, error) {
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var app models.MembershipApplication
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&app, c.Param("id")).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// 以 pending 为条件更新，避免两位干部同时处理同一申请
+		result := tx.Model(&models.MembershipApplication{}).
+			Where("id = ? AND status = ?", app.ID, ApplicationPending).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reason":      reason,
+				"reviewed_by": actorCN,
+				"reviewed_at": &now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errApplicationReviewed
+		}
+		app.Status, app.Reason, app.ReviewedBy, app.ReviewedAt = status, reason, actorCN, &now
+
+		if apply != nil {
+			return apply(tx, app)
+		}
+		return nil
+	})
+	return app, err
+}
+
+func reviewError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "申请不存在"})
+	case errors.Is(err, errApplicationReviewed):
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "处理申请失败"})
+	}
+}
+
+// ApproveApplication 通过入社申请：成员转为社团成员，并按需设置职务、年级与方向
+func ApproveApplication(c echo.Context) error {
+	type ApproveRequest struct {
+		Position  string `json:"position"`
+		Year      string `json:"year"`
+		Direction string `json:"direction"`
+	}
+
+	var req ApproveRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if err := normalizeMemberInput(memberVocabFields{Position: &req.Position, Year: &req.Year, Direction: &req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var before, after models.ClubMember
+	app, err := reviewApplication(c, ApplicationApproved, "", func(tx *gorm.DB, app models.MembershipApplication) error {
+		if err := tx.Where("cn = ?", app.CN).First(&before).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"is_member": true, "status": models.MemberStatusActive}
+		if req.Position != "" {
+			updates["position"] = req.Position
+		}
+		if req.Year != "" {
+			updates["year"] = req.Year
+		}
+		if req.Direction != "" {
+			updates["direction"] = req.Direction
+		}
+		if err := tx.Model(&models.ClubMember{}).Where("cn = ?", app.CN).Updates(updates).Error; err != nil {
+			return err
+		}
+		if before.Status != models.MemberStatusActive {
+			if err := tx.Create(&models.StatusChange{CN: app.CN, FromStatus: before.Status, ToStatus: models.MemberStatusActive, Reason: "入社申请通过", ChangedBy: app.ReviewedBy}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("cn = ?", app.CN).First(&after).Error
+	})
+	if err != nil {
+		return reviewError(c, err)
+	}
+	recordAudit(c, AuditApplicationApprove, "member", app.CN, toClubMemberPublic(before, nil), toClubMemberPublic(after, nil))
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":     "已通过入社申请，成员重新登录或刷新 token 后生效",
+		"application": applicationResponse(app, &after),
+	})
+}
+
+// RejectApplication 拒绝入社申请，需填写原因
+func RejectApplication(c echo.Context) error {
+	type RejectRequest struct {
+		Reason string `json:"reason"`
+	}
+
+	var req RejectRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "拒绝原因不能为空"})
+	}
+
+	app, err := reviewApplication(c, ApplicationRejected, req.Reason, nil)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	recordAudit(c, AuditApplicationReject, "member", app.CN, nil, echo.Map{"reason": req.Reason})
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":     "已拒绝入社申请",
+		"application": applicationResponse(app, nil),
+	})
+}
+
+// GetMyApplication 访客查看自己最近一次入社申请的状态
+func GetMyApplication(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+
+	var app models.MembershipApplication
+	if err := config.DB.Where("cn = ?", cn).Order("created_at desc").First(&app).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "没有入社申请记录"})
+	}
+	return c.JSON(http.StatusOK, applicationResponse(app, nil))
+}
+
+// SubmitApplication 访客（重新）提交入社申请，例如上一次申请被拒绝后
+func SubmitApplication(c echo.Context) error {
+	type SubmitRequest struct {
+		Message string `json:"message"`
+	}
+
+	cn, _ := c.Get("user_cn").(string)
+
+	var req SubmitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "用户不存在"})
+	}
+	if member.IsMember {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "你已经是社团成员"})
+	}
+
+	var pending int64
+	config.DB.Model(&models.MembershipApplication{}).Where("cn = ? AND status = ?", cn, ApplicationPending).Count(&pending)
+	if pending > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "已有待审核的申请"})
+	}
+
+	app := models.MembershipApplication{CN: cn, Status: ApplicationPending, Message: req.Message}
+	if err := config.DB.Create(&app).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "提交申请失败"})
+	}
+	return c.JSON(http.StatusCreated, applicationResponse(app, nil))
+}
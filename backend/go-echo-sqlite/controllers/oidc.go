@@ -0,0 +1,679 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// OIDC 登录配置（任何符合标准的提供方均可，授权码流程 + PKCE）：
+//   OIDC_ISSUER             提供方 issuer，通过 {issuer}/.well-known/openid-configuration 自动发现端点
+//   OIDC_CLIENT_ID          客户端 ID
+//   OIDC_CLIENT_SECRET      客户端密钥，公共客户端可留空（仅依赖 PKCE）
+//   OIDC_REDIRECT_URL       回调地址，即本服务的 /api/oidc/callback
+//   OIDC_SCOPES             申请的 scope，默认 "openid profile email"
+//   OIDC_FRONTEND_REDIRECT  登录完成后跳转的前端地址，结果放在 URL fragment 中（登录成功时只带一次性 ticket，
+//                           前端调用 POST /api/oidc/exchange 换取 token）；留空则回调直接返回 JSON
+//   OIDC_AUTO_REGISTER      为 true 时未绑定的外部身份自动注册为待审核访客
+//   OIDC_CN_CLAIM           自动注册时作为 CN 的 claim，默认 preferred_username
+//
+// 授权请求通过 HttpOnly cookie 与发起它的浏览器绑定，回调时 cookie 不匹配即拒绝，
+// 防止把他人发起的授权链接发给受害者完成登录或绑定（登录 CSRF / 身份错绑）
+
+const (
+	oidcStateTTL      = 10 * time.Minute
+	oidcTicketTTL     = time.Minute
+	oidcBrowserCookie = "scvg_oidc"
+	oidcCookiePath    = "/api/oidc"
+)
+
+type oidcSettings struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       string
+}
+
+func loadOIDCSettings() (oidcSettings, bool) {
+	s := oidcSettings{
+		Issuer:       strings.TrimRight(strings.TrimSpace(os.Getenv("OIDC_ISSUER")), "/"),
+		ClientID:     strings.TrimSpace(os.Getenv("OIDC_CLIENT_ID")),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  strings.TrimSpace(os.Getenv("OIDC_REDIRECT_URL")),
+		Scopes:       strings.TrimSpace(os.Getenv("OIDC_SCOPES")),
+	}
+	if s.Scopes == "" {
+		s.Scopes = "openid profile email"
+	}
+	return s, s.Issuer != "" && s.ClientID != "" && s.RedirectURL != ""
+}
+
+func oidcCNClaim() string {
+	if claim := strings.TrimSpace(os.Getenv("OIDC_CN_CLAIM")); claim != "" {
+		return claim
+	}
+	return "preferred_username"
+}
+
+// oidcProvider 发现文档中用到的字段
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcCache 缓存发现文档与 JWKS；遇到未知 kid 时重新拉取 JWKS 以支持提供方轮换密钥
+var oidcCache struct {
+	sync.Mutex
+	issuer   string
+	provider *oidcProvider
+	keys     map[string]interface{}
+	fetched  time.Time
+}
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func oidcGetJSON(u string, v interface{}) error {
+	resp, err := oidcHTTPClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discoverOIDC 读取（并缓存）提供方的发现文档
+func discoverOIDC(s oidcSettings) (*oidcProvider, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	if oidcCache.provider != nil && oidcCache.issuer == s.Issuer {
+		return oidcCache.provider, nil
+	}
+
+	var p oidcProvider
+	if err := oidcGetJSON(s.Issuer+"/.well-known/openid-configuration", &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(p.Issuer, "/") != s.Issuer {
+		return nil, fmt.Errorf("发现文档中的 issuer %q 与配置不一致", p.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少必要的端点")
+	}
+	oidcCache.issuer = s.Issuer
+	oidcCache.provider = &p
+	oidcCache.keys = nil
+	return &p, nil
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func parseJWK(k oidcJWK) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线 %s", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %s", k.Kty)
+}
+
+// oidcKey 按 kid 取提供方公钥，未命中时最多每分钟重新拉取一次 JWKS
+func oidcKey(p *oidcProvider, kid string) (interface{}, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	lookup := func() (interface{}, bool) {
+		if kid == "" && len(oidcCache.keys) == 1 {
+			for _, k := range oidcCache.keys {
+				return k, true
+			}
+		}
+		k, ok := oidcCache.keys[kid]
+		return k, ok
+	}
+	if k, ok := lookup(); ok {
+		return k, nil
+	}
+	if oidcCache.keys != nil && time.Since(oidcCache.fetched) < time.Minute {
+		return nil, errors.New("未知的签名密钥")
+	}
+
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := oidcGetJSON(p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := parseJWK(jwk); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	oidcCache.keys = keys
+	oidcCache.fetched = time.Now()
+
+	if k, ok := lookup(); ok {
+		return k, nil
+	}
+	return nil, errors.New("未知的签名密钥")
+}
+
+// oidcIDTokenClaims ID Token 中使用的声明；OIDC_CN_CLAIM 指定的声明从完整 payload 中读取
+type oidcIDTokenClaims struct {
+	Nonce string `json:"nonce"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func verifyIDToken(s oidcSettings, p *oidcProvider, raw, nonce string) (*oidcIDTokenClaims, map[string]interface{}, error) {
+	claims := &oidcIDTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return oidcKey(p, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(s.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.Subject == "" {
+		return nil, nil, errors.New("ID Token 缺少 sub")
+	}
+	if claims.Nonce != nonce {
+		return nil, nil, errors.New("ID Token nonce 不匹配")
+	}
+
+	// 已通过签名校验，再解析一次 payload 取全部声明
+	extra := map[string]interface{}{}
+	if parts := strings.Split(raw, "."); len(parts) == 3 {
+		if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			_ = json.Unmarshal(payload, &extra)
+		}
+	}
+	return claims, extra, nil
+}
+
+// exchangeOIDCCode 用授权码与 PKCE verifier 换取 ID Token
+func exchangeOIDCCode(s oidcSettings, p *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.RedirectURL)
+	form.Set("client_id", s.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("令牌端点返回错误: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("令牌端点未返回 id_token")
+	}
+	return body.IDToken, nil
+}
+
+// setOIDCBrowserCookie 写入（value 为空时清除）绑定授权请求的 cookie；
+// 回调是从提供方跳回的顶层 GET 请求，SameSite 只能用 Lax
+func setOIDCBrowserCookie(c echo.Context, s oidcSettings, value string) {
+	cookie := &http.Cookie{
+		Name:     oidcBrowserCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	c.SetCookie(cookie)
+}
+
+// oidcBrowserMatches 请求携带的 cookie 是否与授权请求记录的哈希一致
+func oidcBrowserMatches(c echo.Context, browserHash string) bool {
+	cookie, err := c.Cookie(oidcBrowserCookie)
+	if err != nil || cookie.Value == "" || browserHash == "" {
+		return false
+	}
+	return hmac.Equal([]byte(hashToken(cookie.Value)), []byte(browserHash))
+}
+
+// beginOIDC 生成 state/nonce/PKCE 与浏览器绑定 cookie，返回授权地址；linkCN 非空表示绑定流程
+func beginOIDC(c echo.Context, linkCN string) (string, error) {
+	s, ok := loadOIDCSettings()
+	if !ok {
+		return "", errors.New("未配置 OIDC 登录")
+	}
+	p, err := discoverOIDC(s)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	browser, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	config.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+	if err := config.DB.Create(&models.OIDCLoginState{
+		State:        state,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkCN:       linkCN,
+		BrowserHash:  hashToken(browser),
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	setOIDCBrowserCookie(c, s, browser)
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", s.ClientID)
+	q.Set("redirect_uri", s.RedirectURL)
+	q.Set("scope", s.Scopes)
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// OIDCLogin 跳转到 OIDC 提供方登录
+func OIDCLogin(c echo.Context) error {
+	authURL, err := beginOIDC(c, "")
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": err.Error()})
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCLink 已登录成员发起外部身份绑定，返回授权地址；需在同一浏览器中打开该地址
+func OIDCLink(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+	authURL, err := beginOIDC(c, cn)
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"authorize_url": authURL})
+}
+
+// oidcRespond 按 OIDC_FRONTEND_REDIRECT 跳回前端（结果放在 fragment 中），未配置时直接返回 JSON
+func oidcRespond(c echo.Context, status int, body echo.Map) error {
+	frontend := strings.TrimSpace(os.Getenv("OIDC_FRONTEND_REDIRECT"))
+	if frontend == "" {
+		return c.JSON(status, body)
+	}
+	values := url.Values{}
+	for k, v := range body {
+		values.Set(k, fmt.Sprint(v))
+	}
+	return c.Redirect(http.StatusFound, frontend+"#"+values.Encode())
+}
+
+// OIDCCallback 提供方回调：校验 state、换取并验证 ID Token，然后登录或完成绑定
+func OIDCCallback(c echo.Context) error {
+	if errCode := c.QueryParam("error"); errCode != "" {
+		return oidcRespond(c, http.StatusUnauthorized, echo.Map{"error": "外部登录失败: " + errCode})
+	}
+
+	s, ok := loadOIDCSettings()
+	if !ok {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": "未配置 OIDC 登录"})
+	}
+
+	// state 一次性使用
+	var state models.OIDCLoginState
+	if err := config.DB.Where("state = ?", c.QueryParam("state")).First(&state).Error; err != nil {
+		return oidcRespond(c, http.StatusBadRequest, echo.Map{"error": "登录请求无效或已过期"})
+	}
+	config.DB.Delete(&state)
+	if time.Now().After(state.ExpiresAt) {
+		return oidcRespond(c, http.StatusBadRequest, echo.Map{"error": "登录请求无效或已过期"})
+	}
+	if !oidcBrowserMatches(c, state.BrowserHash) {
+		return oidcRespond(c, http.StatusBadRequest, echo.Map{"error": "登录请求不是由当前浏览器发起的，请重新登录"})
+	}
+
+	p, err := discoverOIDC(s)
+	if err != nil {
+		return oidcRespond(c, http.StatusBadGateway, echo.Map{"error": "无法连接 OIDC 提供方"})
+	}
+	rawIDToken, err := exchangeOIDCCode(s, p, c.QueryParam("code"), state.CodeVerifier)
+	if err != nil {
+		fmt.Printf("OIDC 授权码换取失败: %v\n", err)
+		return oidcRespond(c, http.StatusUnauthorized, echo.Map{"error": "外部登录失败"})
+	}
+	claims, extra, err := verifyIDToken(s, p, rawIDToken, state.Nonce)
+	if err != nil {
+		fmt.Printf("OIDC ID Token 校验失败: %v\n", err)
+		return oidcRespond(c, http.StatusUnauthorized, echo.Map{"error": "外部登录失败"})
+	}
+
+	if state.LinkCN != "" {
+		setOIDCBrowserCookie(c, s, "")
+		return oidcCompleteLink(c, p.Issuer, state.LinkCN, claims)
+	}
+
+	var identity models.ExternalIdentity
+	err = config.DB.Where("issuer = ? AND subject = ?", p.Issuer, claims.Subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !envBool("OIDC_AUTO_REGISTER") {
+			return oidcRespond(c, http.StatusForbidden, echo.Map{"error": "该外部账号尚未绑定成员，请先使用密码登录后绑定"})
+		}
+		identity, err = oidcAutoRegister(c, p.Issuer, claims, extra)
+		if err != nil {
+			return oidcRespond(c, http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+	} else if err != nil {
+		return oidcRespond(c, http.StatusInternalServerError, echo.Map{"error": "登录失败"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", identity.CN).First(&member).Error; err != nil {
+		return oidcRespond(c, http.StatusUnauthorized, echo.Map{"error": "绑定的成员不存在"})
+	}
+
+	now := time.Now()
+	config.DB.Model(&identity).UpdateColumns(map[string]interface{}{
+		"last_used_at": &now,
+		"email":        claims.Email,
+		"name":         claims.Name,
+	})
+	recordAuthSuccess(c, authActionLoginOIDC, member.CN)
+
+	// 跳回前端时只带一次性 ticket，避免 token 出现在 URL、浏览器历史与 Referer 中
+	if strings.TrimSpace(os.Getenv("OIDC_FRONTEND_REDIRECT")) != "" {
+		ticket, err := randomToken(32)
+		if err != nil {
+			return oidcRespond(c, http.StatusInternalServerError, echo.Map{"error": "登录失败"})
+		}
+		config.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginTicket{})
+		if err := config.DB.Create(&models.OIDCLoginTicket{
+			TicketHash:  hashToken(ticket),
+			CN:          member.CN,
+			BrowserHash: state.BrowserHash,
+			ExpiresAt:   time.Now().Add(oidcTicketTTL),
+		}).Error; err != nil {
+			return oidcRespond(c, http.StatusInternalServerError, echo.Map{"error": "登录失败"})
+		}
+		// 保留 cookie：换取 ticket 时仍需同一浏览器
+		return oidcRespond(c, http.StatusOK, echo.Map{"ticket": ticket, "cn": member.CN})
+	}
+
+	setOIDCBrowserCookie(c, s, "")
+	status, body := loginResult(c, member)
+	return c.JSON(status, body)
+}
+
+// OIDCExchange 前端用回调带回的一次性 ticket 换取登录结果（token 或两步验证挑战）
+func OIDCExchange(c echo.Context) error {
+	var req struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := c.Bind(&req); err != nil || req.Ticket == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	// ticket 一次性使用
+	var ticket models.OIDCLoginTicket
+	if err := config.DB.Where("ticket_hash = ?", hashToken(req.Ticket)).First(&ticket).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "登录凭据无效或已过期"})
+	}
+	config.DB.Delete(&ticket)
+	if time.Now().After(ticket.ExpiresAt) || !oidcBrowserMatches(c, ticket.BrowserHash) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "登录凭据无效或已过期"})
+	}
+	s, _ := loadOIDCSettings()
+	setOIDCBrowserCookie(c, s, "")
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", ticket.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "绑定的成员不存在"})
+	}
+	status, body := loginResult(c, member)
+	return c.JSON(status, body)
+}
+
+func oidcCompleteLink(c echo.Context, issuer, cn string, claims *oidcIDTokenClaims) error {
+	var existing models.ExternalIdentity
+	if err := config.DB.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&existing).Error; err == nil {
+		if existing.CN == cn {
+			return oidcRespond(c, http.StatusOK, echo.Map{"message": "该外部账号已绑定", "cn": cn})
+		}
+		return oidcRespond(c, http.StatusConflict, echo.Map{"error": "该外部账号已绑定其他成员"})
+	}
+
+	identity := models.ExternalIdentity{
+		Issuer:  issuer,
+		Subject: claims.Subject,
+		CN:      cn,
+		Email:   claims.Email,
+		Name:    claims.Name,
+	}
+	if err := config.DB.Create(&identity).Error; err != nil {
+		return oidcRespond(c, http.StatusInternalServerError, echo.Map{"error": "绑定失败"})
+	}
+	c.Set("user_cn", cn)
+	recordAudit(c, AuditIdentityLink, "member", cn, nil, echo.Map{"issuer": issuer, "subject": claims.Subject})
+	return oidcRespond(c, http.StatusOK, echo.Map{"message": "外部账号绑定成功", "cn": cn})
+}
+
+// oidcAutoRegister 以外部身份创建待审核访客，密码为随机值（只能通过外部登录或重置密码使用）
+func oidcAutoRegister(c echo.Context, issuer string, claims *oidcIDTokenClaims, extra map[string]interface{}) (models.ExternalIdentity, error) {
+	cn, _ := extra[oidcCNClaim()].(string)
+	cn = strings.TrimSpace(cn)
+	if cn == "" {
+		return models.ExternalIdentity{}, errors.New("外部账号缺少可用作用户名的信息")
+	}
+
+	// 回收站中的成员同样占用该用户名
+	var count int64
+	config.DB.Unscoped().Model(&models.ClubMember{}).Where("cn = ?", cn).Count(&count)
+	if count > 0 {
+		return models.ExternalIdentity{}, errors.New("用户名已被占用，请先使用密码登录后绑定")
+	}
+	if isMemberAlias(cn) {
+		return models.ExternalIdentity{}, errRenameAlias
+	}
+
+	random, err := randomToken(32)
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+	hashed, err := hashPassword(random)
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	identity := models.ExternalIdentity{
+		Issuer:  issuer,
+		Subject: claims.Subject,
+		CN:      cn,
+		Email:   claims.Email,
+		Name:    claims.Name,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		member := models.ClubMember{CN: cn, Password: hashed, Status: initialMemberStatus("", false)}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.StatusChange{CN: cn, ToStatus: member.Status, Reason: "外部账号自动注册", ChangedBy: cn}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&member).Update("is_member", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.MembershipApplication{CN: cn, Status: ApplicationPending}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return models.ExternalIdentity{}, errors.New("自动注册失败")
+	}
+	c.Set("user_cn", cn)
+	recordAudit(c, AuditMemberRegister, "member", cn, nil, echo.Map{"is_member": false, "issuer": issuer})
+	return identity, nil
+}
+
+// ListMyIdentities 查看当前成员绑定的外部身份
+func ListMyIdentities(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+
+	var identities []models.ExternalIdentity
+	if err := config.DB.Where("cn = ?", cn).Order("created_at").Find(&identities).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(identities))
+	for _, i := range identities {
+		items = append(items, echo.Map{
+			"id":           i.ID,
+			"issuer":       i.Issuer,
+			"subject":      i.Subject,
+			"email":        i.Email,
+			"name":         i.Name,
+			"last_used_at": i.LastUsedAt,
+			"created_at":   i.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// UnlinkMyIdentity 解除外部身份绑定
+func UnlinkMyIdentity(c echo.Context) error {
+	cn, _ := c.Get("user_cn").(string)
+	id := c.Param("id")
+
+	var identity models.ExternalIdentity
+	if err := config.DB.Where("cn = ?", cn).First(&identity, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "绑定不存在"})
+	}
+	if err := config.DB.Unscoped().Delete(&identity).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "解除绑定失败"})
+	}
+	recordAudit(c, AuditIdentityUnlink, "member", cn, echo.Map{"issuer": identity.Issuer, "subject": identity.Subject}, nil)
+	return c.NoContent(http.StatusNoContent)
+}
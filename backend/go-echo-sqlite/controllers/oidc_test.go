@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const mockClientID = "scvg-test"
+
+// mockOIDC 最小的 OIDC 提供方：发现文档、JWKS 与校验 PKCE 的令牌端点
+type mockOIDC struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant // 授权码 -> 授权请求
+	nonce  string               // 非空时签发的 ID Token 使用该 nonce
+}
+
+type mockGrant struct {
+	subject   string
+	nonce     string
+	challenge string
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.srv.URL,
+			"authorization_endpoint": m.srv.URL + "/authorize",
+			"token_endpoint":         m.srv.URL + "/token",
+			"jwks_uri":               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		grant, ok := m.grants[r.PostForm.Get("code")]
+		delete(m.grants, r.PostForm.Get("code"))
+		override := m.nonce
+		m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		nonce := grant.nonce
+		if override != "" {
+			nonce = override
+		}
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   m.srv.URL,
+			"aud":   mockClientID,
+			"sub":   grant.subject,
+			"nonce": nonce,
+			"email": grant.subject + "@example.com",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+		})
+		token.Header["kid"] = "k1"
+		raw, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": raw, "token_type": "Bearer"})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// authorize 模拟用户在提供方以 subject 登录并同意授权，返回授权码与 state
+func (m *mockOIDC) authorize(t *testing.T, authURL, subject string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != mockClientID || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorize request: %s", authURL)
+	}
+	code, _ = randomToken(16)
+	m.mu.Lock()
+	m.grants[code] = mockGrant{subject: subject, nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	m.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func setupOIDCTest(t *testing.T) *mockOIDC {
+	t.Helper()
+	newTestDB(t)
+
+	m := newMockOIDC(t)
+	t.Setenv("OIDC_ISSUER", m.srv.URL)
+	t.Setenv("OIDC_CLIENT_ID", mockClientID)
+	t.Setenv("OIDC_REDIRECT_URL", "https://scvg.example/api/oidc/callback")
+	t.Setenv("OIDC_FRONTEND_REDIRECT", "")
+	t.Setenv("OIDC_AUTO_REGISTER", "")
+	return m
+}
+
+func createOIDCMember(t *testing.T, cn, subject, issuer string) {
+	t.Helper()
+	if err := config.DB.Create(&models.ClubMember{CN: cn, Password: "x", IsMember: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if subject != "" {
+		if err := config.DB.Create(&models.ExternalIdentity{Issuer: issuer, Subject: subject, CN: cn}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// serve 调用处理函数，cookie 为 nil 表示请求不携带浏览器绑定 cookie
+func serve(h echo.HandlerFunc, method, target, body string, cookie *http.Cookie, set map[string]interface{}) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	for k, v := range set {
+		c.Set(k, v)
+	}
+	h(c)
+	return rec
+}
+
+func browserCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == oidcBrowserCookie && ck.Value != "" {
+			if !ck.HttpOnly || ck.SameSite != http.SameSiteLaxMode || !ck.Secure {
+				t.Errorf("cookie attributes: %+v", ck)
+			}
+			return ck
+		}
+	}
+	t.Fatal("no browser binding cookie set")
+	return nil
+}
+
+func callbackURL(code, state string) string {
+	return "/api/oidc/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+}
+
+func TestOIDCLoginRequiresSameBrowser(t *testing.T) {
+	m := setupOIDCTest(t)
+	createOIDCMember(t, "alice", "alice-sub", m.srv.URL)
+
+	// 同一浏览器完成登录
+	rec := serve(OIDCLogin, http.MethodGet, "/api/oidc/login", "", nil, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+	cookie := browserCookie(t, rec)
+	code, state := m.authorize(t, rec.Header().Get("Location"), "alice-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", cookie, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: %d %s", rec.Code, rec.Body)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["token"] == nil || body["refresh_token"] == nil || body["cn"] != "alice" {
+		t.Fatalf("callback body: %v", body)
+	}
+
+	// 攻击者发起的授权请求在受害者浏览器中完成：没有对应 cookie，必须拒绝
+	rec = serve(OIDCLogin, http.MethodGet, "/api/oidc/login", "", nil, nil)
+	code, state = m.authorize(t, rec.Header().Get("Location"), "alice-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", &http.Cookie{Name: oidcBrowserCookie, Value: "other"}, nil)
+	if rec.Code != http.StatusBadRequest || strings.Contains(rec.Body.String(), "token") {
+		t.Fatalf("foreign browser callback: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCLinkRejectsForeignBrowser(t *testing.T) {
+	m := setupOIDCTest(t)
+	createOIDCMember(t, "attacker", "", "")
+
+	// 攻击者为自己的 CN 发起绑定，把授权地址发给受害者
+	rec := serve(OIDCLink, http.MethodPost, "/api/oidc/link", "", nil, map[string]interface{}{"user_cn": "attacker"})
+	var link struct {
+		AuthorizeURL string `json:"authorize_url"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &link)
+	attackerCookie := browserCookie(t, rec)
+
+	code, state := m.authorize(t, link.AuthorizeURL, "victim-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", nil, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("victim callback: %d %s", rec.Code, rec.Body)
+	}
+	var count int64
+	config.DB.Model(&models.ExternalIdentity{}).Where("subject = ?", "victim-sub").Count(&count)
+	if count != 0 {
+		t.Fatal("victim identity was linked to the attacker")
+	}
+
+	// 发起绑定的浏览器自己完成授权则正常绑定
+	rec = serve(OIDCLink, http.MethodPost, "/api/oidc/link", "", nil, map[string]interface{}{"user_cn": "attacker"})
+	json.Unmarshal(rec.Body.Bytes(), &link)
+	attackerCookie = browserCookie(t, rec)
+	code, state = m.authorize(t, link.AuthorizeURL, "attacker-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", attackerCookie, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("own link: %d %s", rec.Code, rec.Body)
+	}
+	config.DB.Model(&models.ExternalIdentity{}).Where("subject = ? AND cn = ?", "attacker-sub", "attacker").Count(&count)
+	if count != 1 {
+		t.Fatal("own identity not linked")
+	}
+}
+
+func TestOIDCFrontendRedirectCarriesTicketNotTokens(t *testing.T) {
+	m := setupOIDCTest(t)
+	t.Setenv("OIDC_FRONTEND_REDIRECT", "https://app.example/oidc")
+	createOIDCMember(t, "alice", "alice-sub", m.srv.URL)
+
+	rec := serve(OIDCLogin, http.MethodGet, "/api/oidc/login", "", nil, nil)
+	cookie := browserCookie(t, rec)
+	code, state := m.authorize(t, rec.Header().Get("Location"), "alice-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", cookie, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: %d %s", rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	fragment, _ := url.ParseQuery(location[strings.Index(location, "#")+1:])
+	if fragment.Get("token") != "" || fragment.Get("refresh_token") != "" || fragment.Get("ticket") == "" {
+		t.Fatalf("redirect must carry only a ticket: %s", location)
+	}
+
+	exchange := `{"ticket":"` + fragment.Get("ticket") + `"}`
+	rec = serve(OIDCExchange, http.MethodPost, "/api/oidc/exchange", exchange, cookie, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "refresh_token") {
+		t.Fatalf("exchange: %d %s", rec.Code, rec.Body)
+	}
+	// ticket 只能使用一次
+	rec = serve(OIDCExchange, http.MethodPost, "/api/oidc/exchange", exchange, cookie, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("replayed ticket: %d %s", rec.Code, rec.Body)
+	}
+
+	// 其他浏览器拿到 ticket 也无法换取
+	rec = serve(OIDCLogin, http.MethodGet, "/api/oidc/login", "", nil, nil)
+	cookie = browserCookie(t, rec)
+	code, state = m.authorize(t, rec.Header().Get("Location"), "alice-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", cookie, nil)
+	location = rec.Header().Get("Location")
+	fragment, _ = url.ParseQuery(location[strings.Index(location, "#")+1:])
+	rec = serve(OIDCExchange, http.MethodPost, "/api/oidc/exchange", `{"ticket":"`+fragment.Get("ticket")+`"}`, nil, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("exchange without cookie: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	m := setupOIDCTest(t)
+	createOIDCMember(t, "alice", "alice-sub", m.srv.URL)
+	m.nonce = "forged"
+
+	rec := serve(OIDCLogin, http.MethodGet, "/api/oidc/login", "", nil, nil)
+	cookie := browserCookie(t, rec)
+	code, state := m.authorize(t, rec.Header().Get("Location"), "alice-sub")
+	rec = serve(OIDCCallback, http.MethodGet, callbackURL(code, state), "", cookie, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("nonce mismatch: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCAutoRegisterCreatesApplicant(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("BCRYPT_COST", "4")
+	// 回收站中的管理员 boss 仍保留用户名
+	createOIDCMember(t, "boss", "", "")
+	if err := config.DB.Where("cn = ?", "boss").Delete(&models.ClubMember{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	register := func(cn, subject string) error {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		claims := &oidcIDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}
+		_, err := oidcAutoRegister(c, "https://idp.example", claims, map[string]interface{}{"preferred_username": cn})
+		return err
+	}
+	if err := register("boss", "boss-sub"); err == nil {
+		t.Fatal("registered the CN of a trashed member")
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.ClubMember{}).Where("cn = ?", "boss").Count(&count)
+	if count != 1 {
+		t.Fatalf("%d rows for boss", count)
+	}
+
+	if err := register("newbie", "newbie-sub"); err != nil {
+		t.Fatal(err)
+	}
+	var m models.ClubMember
+	config.DB.Where("cn = ?", "newbie").First(&m)
+	if m.IsMember || m.Status != models.MemberStatusApplicant {
+		t.Fatalf("auto-registered member: is_member=%v status=%q", m.IsMember, m.Status)
+	}
+	var change models.StatusChange
+	if err := config.DB.Where("cn = ?", "newbie").First(&change).Error; err != nil || change.ToStatus != models.MemberStatusApplicant {
+		t.Fatalf("status change: %+v %v", change, err)
+	}
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	errOrgPositionTaken = errors.New("已存在同名职位")
+	errOrgSeatsFull     = errors.New("该职位本学年名额已满")
+	errOrgAssigned      = errors.New("该成员本学年已担任此职位")
+)
+
+// defaultOrgPositions 首次启动时创建的组织架构，之后可通过接口调整
+var defaultOrgPositions = []struct {
+	Name      string
+	Parent    string
+	Line      string
+	SeatLimit int
+}{
+	{"社长", "", models.OrgLineGeneral, 1},
+	{"副社长", "社长", models.OrgLineGeneral, 2},
+	{"MAD 组长", "社长", models.OrgLineMAD, 1},
+	{"MMD 组长", "社长", models.OrgLineMMD, 1},
+}
+
+// SeedOrgPositions 职位表为空时写入默认组织架构
+func SeedOrgPositions() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.OrgPosition{}).Count(&count).Error; err != nil || count > 0 {
+			return err
+		}
+		ids := make(map[string]uint)
+		for i, d := range defaultOrgPositions {
+			p := models.OrgPosition{Name: d.Name, Line: d.Line, SeatLimit: d.SeatLimit, SortOrder: i}
+			if d.Parent != "" {
+				parentID := ids[d.Parent]
+				p.ParentID = &parentID
+			}
+			if err := tx.Create(&p).Error; err != nil {
+				return err
+			}
+			ids[d.Name] = p.ID
+		}
+		return nil
+	})
+}
+
+func orgPositionResponse(p models.OrgPosition) echo.Map {
+	return echo.Map{
+		"id":          p.ID,
+		"name":        p.Name,
+		"parent_id":   p.ParentID,
+		"line":        p.Line,
+		"line_label":  models.OrgLineLabel(p.Line),
+		"seat_limit":  p.SeatLimit,
+		"sort_order":  p.SortOrder,
+		"description": p.Description,
+	}
+}
+
+// validateOrgPosition 校验名称、条线、名额与上级职位（不能形成环）
+func validateOrgPosition(p models.OrgPosition) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("职位名称不能为空")
+	}
+	if !containsString(models.OrgLines, p.Line) {
+		return fmt.Errorf("条线只能为 %s", strings.Join(models.OrgLines, "、"))
+	}
+	if p.SeatLimit < 0 {
+		return errors.New("名额不能为负数")
+	}
+	var count int64
+	config.DB.Model(&models.OrgPosition{}).Where("name = ? AND id <> ?", p.Name, p.ID).Count(&count)
+	if count > 0 {
+		return errOrgPositionTaken
+	}
+
+	// 沿上级链向上查找，遇到自身即为环
+	seen := map[uint]bool{p.ID: true}
+	for parentID := p.ParentID; parentID != nil; {
+		if seen[*parentID] {
+			return errors.New("上级职位不能是自身或下级职位")
+		}
+		seen[*parentID] = true
+		var parent models.OrgPosition
+		if err := config.DB.First(&parent, *parentID).Error; err != nil {
+			return errors.New("上级职位不存在")
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+func orgPositionError(c echo.Context, err error) error {
+	if errors.Is(err, errOrgPositionTaken) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+}
+
+// ListOrgPositions 列出全部职位
+func ListOrgPositions(c echo.Context) error {
+	var positions []models.OrgPosition
+	if err := config.DB.Order("sort_order asc, id asc").Find(&positions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	items := make([]echo.Map, 0, len(positions))
+	for _, p := range positions {
+		items = append(items, orgPositionResponse(p))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+type orgPositionRequest struct {
+	Name        *string `json:"name"`
+	ParentID    *uint   `json:"parent_id"`
+	Line        *string `json:"line"`
+	SeatLimit   *int    `json:"seat_limit"`
+	SortOrder   *int    `json:"sort_order"`
+	Description *string `json:"description"`
+	// ClearParent 为 true 时移到顶层
+	ClearParent bool `json:"clear_parent"`
+}
+
+func (req orgPositionRequest) apply(p *models.OrgPosition) {
+	if req.Name != nil {
+		p.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.ParentID != nil {
+		p.ParentID = req.ParentID
+	}
+	if req.ClearParent {
+		p.ParentID = nil
+	}
+	if req.Line != nil {
+		p.Line = *req.Line
+	}
+	if req.SeatLimit != nil {
+		p.SeatLimit = *req.SeatLimit
+	}
+	if req.SortOrder != nil {
+		p.SortOrder = *req.SortOrder
+	}
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+}
+
+// CreateOrgPosition 新建职位，line 默认为 general
+func CreateOrgPosition(c echo.Context) error {
+	var req orgPositionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	p := models.OrgPosition{Line: models.OrgLineGeneral}
+	req.apply(&p)
+	if err := validateOrgPosition(p); err != nil {
+		return orgPositionError(c, err)
+	}
+	if err := config.DB.Create(&p).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "创建职位失败"})
+	}
+	recordAudit(c, AuditOrgPositionCreate, "org_position", strconv.Itoa(int(p.ID)), nil, orgPositionResponse(p))
+	return c.JSON(http.StatusCreated, orgPositionResponse(p))
+}
+
+// UpdateOrgPosition 修改职位，只更新提供的字段；已有任职记录中的职务名称保持不变
+func UpdateOrgPosition(c echo.Context) error {
+	var p models.OrgPosition
+	if err := config.DB.First(&p, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "职位不存在"})
+	}
+	var req orgPositionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	before := orgPositionResponse(p)
+	req.apply(&p)
+	if err := validateOrgPosition(p); err != nil {
+		return orgPositionError(c, err)
+	}
+	if err := config.DB.Save(&p).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "更新失败"})
+	}
+	recordAudit(c, AuditOrgPositionUpdate, "org_position", c.Param("id"), before, orgPositionResponse(p))
+	return c.JSON(http.StatusOK, orgPositionResponse(p))
+}
+
+// DeleteOrgPosition 删除没有下级职位的职位；历史任职记录保留
+func DeleteOrgPosition(c echo.Context) error {
+	var p models.OrgPosition
+	if err := config.DB.First(&p, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "职位不存在"})
+	}
+	var children int64
+	config.DB.Model(&models.OrgPosition{}).Where("parent_id = ?", p.ID).Count(&children)
+	if children > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "请先删除或移动下级职位"})
+	}
+	if err := config.DB.Delete(&p).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
+	}
+	recordAudit(c, AuditOrgPositionDelete, "org_position", c.Param("id"), orgPositionResponse(p), nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// isOrgPositionName 判断职务是否为组织架构中的职位
+func isOrgPositionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	var count int64
+	config.DB.Model(&models.OrgPosition{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// AssignOrgPosition 任命成员担任某学年的职位（学年默认为当前学年），受名额限制；
+// 任命当前学年时同步更新成员的职务
+func AssignOrgPosition(c echo.Context) error {
+	type AssignRequest struct {
+		CN           string `json:"cn"`
+		AcademicYear string `json:"academic_year"`
+		Note         string `json:"note"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var p models.OrgPosition
+	if err := config.DB.First(&p, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "职位不存在"})
+	}
+	var req AssignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if req.AcademicYear == "" {
+		req.AcademicYear = currentAcademicYear(time.Now())
+	}
+	if req.CN == "" || !validAcademicYear(req.AcademicYear) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "cn 不能为空，学年应为四位年份"})
+	}
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+	if !member.IsMember {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "只能任命社团成员"})
+	}
+
+	tenure := models.Tenure{
+		CN:           member.CN,
+		AcademicYear: req.AcademicYear,
+		Position:     p.Name,
+		PositionID:   &p.ID,
+		Direction:    member.Direction,
+		Status:       member.Status,
+		Note:         req.Note,
+		CreatedBy:    actorCN,
+	}
+	current := req.AcademicYear == currentAcademicYear(time.Now())
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.Tenure{}).
+			Where("position_id = ? AND academic_year = ? AND cn = ?", p.ID, req.AcademicYear, member.CN).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errOrgAssigned
+		}
+		if p.SeatLimit > 0 {
+			var seats int64
+			if err := tx.Model(&models.Tenure{}).
+				Where("position_id = ? AND academic_year = ?", p.ID, req.AcademicYear).
+				Count(&seats).Error; err != nil {
+				return err
+			}
+			if int(seats) >= p.SeatLimit {
+				return errOrgSeatsFull
+			}
+		}
+		if err := tx.Create(&tenure).Error; err != nil {
+			return err
+		}
+		if current {
+			return tx.Model(&models.ClubMember{}).Where("cn = ?", member.CN).Update("position", p.Name).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errOrgAssigned) || errors.Is(err, errOrgSeatsFull) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "任命失败"})
+	}
+
+	recordAudit(c, AuditOrgAssign, "org_position", strconv.Itoa(int(p.ID)), nil, tenureResponse(tenure))
+	if current {
+		syncMembersAsync()
+	}
+	return c.JSON(http.StatusCreated, tenureResponse(tenure))
+}
+
+// UnassignOrgPosition 撤销成员在某学年（?academic_year=，默认当前学年）担任的职位
+func UnassignOrgPosition(c echo.Context) error {
+	year := c.QueryParam("academic_year")
+	if year == "" {
+		year = currentAcademicYear(time.Now())
+	}
+	var tenure models.Tenure
+	if err := config.DB.Where("position_id = ? AND academic_year = ? AND cn = ?", c.Param("id"), year, c.Param("cn")).
+		First(&tenure).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "该成员本学年未担任此职位"})
+	}
+
+	current := year == currentAcademicYear(time.Now())
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&tenure).Error; err != nil {
+			return err
+		}
+		if current {
+			return tx.Model(&models.ClubMember{}).
+				Where("cn = ? AND position = ?", tenure.CN, tenure.Position).
+				Update("position", "").Error
+		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "撤销任命失败"})
+	}
+
+	recordAudit(c, AuditOrgUnassign, "org_position", c.Param("id"), tenureResponse(tenure), nil)
+	if current {
+		syncMembersAsync()
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetOrgChart 返回某学年（?academic_year=，默认当前学年）的组织架构树，节点附带任职成员的公开信息与头像（按成员隐私设置过滤）
+func GetOrgChart(c echo.Context) error {
+	year := c.QueryParam("academic_year")
+	if year == "" {
+		year = currentAcademicYear(time.Now())
+	}
+	if !validAcademicYear(year) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "学年格式错误，应为四位年份"})
+	}
+
+	var positions []models.OrgPosition
+	if err := config.DB.Order("sort_order asc, id asc").Find(&positions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	var tenures []models.Tenure
+	if err := config.DB.Where("academic_year = ? AND position_id IS NOT NULL", year).Order("id asc").Find(&tenures).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	cns := make([]string, 0, len(tenures))
+	for _, t := range tenures {
+		cns = append(cns, t.CN)
+	}
+	var members []models.ClubMember
+	config.DB.Where("cn IN ?", cns).Find(&members)
+	memberByCN := make(map[string]models.ClubMember, len(members))
+	for _, m := range members {
+		memberByCN[m.CN] = m
+	}
+	var profiles []models.MemberProfile
+	config.DB.Select("cn", "avatar").Where("cn IN ?", cns).Find(&profiles)
+	avatarByCN := make(map[string]string, len(profiles))
+	for _, p := range profiles {
+		avatarByCN[p.CN] = p.Avatar
+	}
+
+	view := newPrivacyView(c, cns...)
+	holders := make(map[uint][]echo.Map)
+	for _, t := range tenures {
+		m, ok := memberByCN[t.CN]
+		if !ok {
+			continue
+		}
+		avatar := avatarByCN[t.CN]
+		if !view.visible(t.CN, "avatar") {
+			avatar = ""
+		}
+		holders[*t.PositionID] = append(holders[*t.PositionID], echo.Map{
+			"member": toClubMemberPublic(m, view),
+			"avatar": avatar,
+			"note":   t.Note,
+		})
+	}
+
+	nodes := make(map[uint]echo.Map, len(positions))
+	for _, p := range positions {
+		node := orgPositionResponse(p)
+		members := holders[p.ID]
+		if members == nil {
+			members = []echo.Map{}
+		}
+		node["members"] = members
+		node["children"] = []echo.Map{}
+		nodes[p.ID] = node
+	}
+	// 节点为 map，挂到上级后仍可继续追加子节点；上级职位已删除的职位作为顶层显示
+	roots := []echo.Map{}
+	for _, p := range positions {
+		if p.ParentID != nil {
+			if parent, ok := nodes[*p.ParentID]; ok {
+				parent["children"] = append(parent["children"].([]echo.Map), nodes[p.ID])
+				continue
+			}
+		}
+		roots = append(roots, nodes[p.ID])
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"academic_year": year,
+		"tree":          roots,
+	})
+}
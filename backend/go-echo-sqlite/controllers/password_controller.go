@@ -1,22 +1,160 @@
 package controllers
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-// 忘记密码 - 通过备忘码重置密码
+// memoryCodeTTL 备忘码有效期，可通过 MEMORY_CODE_TTL 配置，默认 10 分钟
+func memoryCodeTTL() time.Duration {
+	return envDuration("MEMORY_CODE_TTL", 10*time.Minute)
+}
+
+// passwordResetTTL 重置令牌有效期，可通过 PASSWORD_RESET_TTL 配置，默认 30 分钟
+func passwordResetTTL() time.Duration {
+	return envDuration("PASSWORD_RESET_TTL", 30*time.Minute)
+}
+
+// IssuePasswordReset 管理员为指定成员签发一次性重置令牌（明文只返回这一次）
+func IssuePasswordReset(c echo.Context) error {
+	type IssueRequest struct {
+		CN string `json:"cn"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req IssueRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "无效的请求格式"})
+	}
+	if req.CN == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "成员姓名不能为空"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "用户不存在"})
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "生成重置令牌失败"})
+	}
+	expiresAt := time.Now().Add(passwordResetTTL())
+
+	// 新令牌签发后，该成员之前未使用的令牌全部作废
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cn = ? AND used_at IS NULL", member.CN).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			CN:        member.CN,
+			TokenHash: hashToken(token),
+			ExpiresAt: expiresAt,
+			IssuedBy:  actorCN,
+		}).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "生成重置令牌失败"})
+	}
+	recordAudit(c, AuditPasswordResetIssue, "member", member.CN, nil, echo.Map{"expires_at": expiresAt})
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"cn":          member.CN,
+		"reset_token": token,
+		"expires_at":  expiresAt,
+		"message":     "重置令牌已生成，请转交该成员，令牌只显示一次",
+	})
+}
+
+var errResetTokenUsed = errors.New("重置令牌已被使用")
+
+// ResetPassword 成员使用重置令牌设置新密码
+func ResetPassword(c echo.Context) error {
+	type ResetPasswordRequest struct {
+		CN          string `json:"cn"`
+		ResetToken  string `json:"reset_token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "无效的请求格式"})
+	}
+	if req.CN == "" || req.ResetToken == "" || req.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "所有字段都不能为空"})
+	}
+	if err := checkPasswordPolicy(req.CN, req.NewPassword); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if blocked, err := authThrottled(c, req.CN); blocked {
+		return err
+	}
+
+	var resetToken models.PasswordResetToken
+	err := config.DB.Where("token_hash = ? AND cn = ? AND used_at IS NULL", hashToken(req.ResetToken), req.CN).
+		First(&resetToken).Error
+	if err != nil || time.Now().After(resetToken.ExpiresAt) {
+		recordAuthFailure(c, authActionResetPassword, req.CN)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "重置令牌无效或已过期"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "用户不存在"})
+	}
+
+	hashedPassword, err := hashPassword(req.NewPassword)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码加密失败"})
+	}
+
+	// 标记令牌已使用、更新密码并撤销全部会话
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		used := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", time.Now())
+		if used.Error != nil {
+			return used.Error
+		}
+		if used.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+		if err := tx.Model(&member).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		_, err := revokeSessionsForCN(tx, member.CN)
+		return err
+	})
+	if err == errResetTokenUsed {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "重置令牌无效或已过期"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码重置失败"})
+	}
+	recordAuthSuccess(c, authActionResetPassword, member.CN)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "密码重置成功，请使用新密码登录"})
+}
+
+// ForgotPassword 成员使用管理员发放的备忘码设置新密码，备忘码使用后立即失效
 func ForgotPassword(c echo.Context) error {
 	type ForgotPasswordRequest struct {
-		CN         string `json:"cn"`
-		MemoryCode string `json:"memory_code"`
+		CN          string `json:"cn"`
+		MemoryCode  string `json:"memory_code"`
+		NewPassword string `json:"new_password"`
 	}
 
 	var req ForgotPasswordRequest
@@ -25,38 +163,67 @@ func ForgotPassword(c echo.Context) error {
 	}
 
 	// 验证输入
-	if req.CN == "" || req.MemoryCode == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "成员姓名和备忘码不能为空"})
+	if req.CN == "" || req.MemoryCode == "" || req.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "成员姓名、备忘码和新密码不能为空"})
+	}
+	if err := checkPasswordPolicy(req.CN, req.NewPassword); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if blocked, err := authThrottled(c, req.CN); blocked {
+		return err
 	}
 
 	// 检查用户是否存在
 	var member models.ClubMember
 	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "用户不存在"})
+		recordAuthFailure(c, authActionForgotPassword, req.CN)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "备忘码无效或已过期"})
 	}
 
-	// 验证备忘码
+	// 验证备忘码：必须是发给该成员、未过期且未使用的
 	var memoryCode models.MemoryCode
-	today := time.Now().Format("2006-01-02")
-	if err := config.DB.Where("code = ? AND date = ?", req.MemoryCode, today).First(&memoryCode).Error; err != nil {
+	if err := config.DB.Where("cn = ? AND code = ? AND consumed_at IS NULL AND expires_at > ?",
+		member.CN, hashToken(strings.TrimSpace(req.MemoryCode)), time.Now()).First(&memoryCode).Error; err != nil {
+		recordAuthFailure(c, authActionForgotPassword, req.CN)
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "备忘码无效或已过期"})
 	}
 
-	// 重置密码为 0721
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("0721"), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.NewPassword)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码重置失败"})
 	}
 
-	member.Password = string(hashedPassword)
-	if err := config.DB.Save(&member).Error; err != nil {
+	// 标记备忘码已使用、更新密码并撤销该成员的全部会话
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.MemoryCode{}).
+			Where("id = ? AND consumed_at IS NULL", memoryCode.ID).
+			Update("consumed_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errMemoryCodeUsed
+		}
+		if err := tx.Model(&member).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		_, err := revokeSessionsForCN(tx, member.CN)
+		return err
+	})
+	if errors.Is(err, errMemoryCodeUsed) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "备忘码无效或已过期"})
+	}
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码重置失败"})
 	}
+	recordAuthSuccess(c, authActionForgotPassword, member.CN)
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "密码已重置为 0721"})
+	return c.JSON(http.StatusOK, map[string]string{"message": "密码重置成功，请使用新密码登录"})
 }
 
-// 修改密码
+// 修改密码（需要登录，只能修改 token 对应成员自己的密码）
 func ChangePassword(c echo.Context) error {
 	type ChangePasswordRequest struct {
 		CN          string `json:"cn"`
@@ -64,93 +231,148 @@ func ChangePassword(c echo.Context) error {
 		NewPassword string `json:"new_password"`
 	}
 
+	actorCN, _ := c.Get("user_cn").(string)
+
 	var req ChangePasswordRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "无效的请求格式"})
 	}
 
+	// 未提供 cn 时默认为 token 中的成员
+	if req.CN == "" {
+		req.CN = actorCN
+	}
+	if req.CN != actorCN {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "只能修改自己的密码"})
+	}
+
 	// 验证输入
-	if req.CN == "" || req.OldPassword == "" || req.NewPassword == "" {
+	if req.OldPassword == "" || req.NewPassword == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "所有字段都不能为空"})
 	}
+	if err := checkPasswordPolicy(req.CN, req.NewPassword); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if blocked, err := authThrottled(c, req.CN); blocked {
+		return err
+	}
 
 	// 检查用户是否存在
 	var member models.ClubMember
 	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		recordAuthFailure(c, authActionChangePassword, req.CN)
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "用户不存在"})
 	}
 
 	// 验证当前密码
 	if err := bcrypt.CompareHashAndPassword([]byte(member.Password), []byte(req.OldPassword)); err != nil {
+		recordAuthFailure(c, authActionChangePassword, req.CN)
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "当前密码错误"})
 	}
 
 	// 加密新密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(req.NewPassword)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码加密失败"})
 	}
 
-	// 更新密码
-	member.Password = string(hashedPassword)
-	if err := config.DB.Save(&member).Error; err != nil {
+	// 更新密码并撤销该成员的全部会话
+	member.Password = hashedPassword
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&member).Error; err != nil {
+			return err
+		}
+		_, err := revokeSessionsForCN(tx, member.CN)
+		return err
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "密码更新失败"})
 	}
+	recordAuthSuccess(c, authActionChangePassword, member.CN)
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "密码修改成功"})
 }
 
-// 获取今日备忘码
-func GetMemoryCode(c echo.Context) error {
-	today := time.Now().Format("2006-01-02")
+var errMemoryCodeUsed = errors.New("备忘码已被使用")
 
-	var memoryCode models.MemoryCode
-	err := config.DB.Where("date = ?", today).First(&memoryCode).Error
+// IssueMemoryCode 管理员为指定成员生成备忘码（随机六位数字，明文只返回这一次）
+func IssueMemoryCode(c echo.Context) error {
+	type IssueRequest struct {
+		CN string `json:"cn"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req IssueRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "无效的请求格式"})
+	}
+	if req.CN == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "成员姓名不能为空"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "用户不存在"})
+	}
 
+	code, err := generateMemoryCode()
 	if err != nil {
-		// 如果今天没有备忘码，生成一个新的
-		newCode := generateMemoryCode(today)
-		memoryCode = models.MemoryCode{
-			Code: newCode,
-			Date: today,
-		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "生成备忘码失败"})
+	}
+	now := time.Now()
+	expiresAt := now.Add(memoryCodeTTL())
 
-		if err := config.DB.Create(&memoryCode).Error; err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "生成备忘码失败"})
+	// 新备忘码生成后，该成员之前未使用的备忘码全部作废
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("cn = ? AND consumed_at IS NULL", member.CN).Delete(&models.MemoryCode{}).Error; err != nil {
+			return err
 		}
+		return tx.Create(&models.MemoryCode{
+			CN:        member.CN,
+			Code:      hashToken(code),
+			Date:      now.Format("2006-01-02"),
+			ExpiresAt: expiresAt,
+			IssuedBy:  actorCN,
+		}).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "生成备忘码失败"})
 	}
+	recordAudit(c, AuditMemoryCodeIssue, "member", member.CN, nil, echo.Map{"expires_at": expiresAt})
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"code": memoryCode.Code,
-		"date": memoryCode.Date,
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"cn":         member.CN,
+		"code":       code,
+		"expires_at": expiresAt,
+		"message":    "备忘码已生成，请当面或私信告知该成员，备忘码只显示一次",
 	})
 }
 
-// 生成基于日期的四位数备忘码
-func generateMemoryCode(dateStr string) string {
-	// 解析日期字符串为时间对象
-	date, err := time.Parse("2006-01-02", dateStr)
+// generateMemoryCode 生成随机六位数字备忘码
+func generateMemoryCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
 	if err != nil {
-		// 如果解析失败，使用当前时间
-		date = time.Now()
+		return "", err
 	}
-
-	// 使用日期的年月日作为种子，确保同一天生成相同的备忘码
-	year, month, day := date.Date()
-	seed := int64(year*10000 + int(month)*100 + day)
-
-	// 设置随机种子
-	rand.Seed(seed)
-
-	// 生成1000-9999之间的四位数
-	code := rand.Intn(9000) + 1000
-
-	return strconv.Itoa(code)
+	return fmt.Sprintf("%06d", n.Int64()), nil
 }
 
-// 清理过期备忘码（可以在定时任务中调用）
+// CleanupExpiredMemoryCodes 删除已过期或已使用的备忘码
 func CleanupExpiredMemoryCodes() {
-	// 删除7天前的备忘码
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
-	config.DB.Where("date < ?", sevenDaysAgo).Delete(&models.MemoryCode{})
+	config.DB.Unscoped().Where("expires_at < ? OR consumed_at IS NOT NULL", time.Now()).Delete(&models.MemoryCode{})
+}
+
+// StartMemoryCodeCleanup 按 MEMORY_CODE_CLEANUP_INTERVAL（默认 10 分钟）定时清理备忘码
+func StartMemoryCodeCleanup() {
+	interval := envDuration("MEMORY_CODE_CLEANUP_INTERVAL", 10*time.Minute)
+	go func() {
+		CleanupExpiredMemoryCodes()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			CleanupExpiredMemoryCodes()
+		}
+	}()
 }
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const newPassword = "N3wSecret!pass"
+
+func setupMemoryCodeTest(t *testing.T) models.ClubMember {
+	t.Helper()
+	newTestDB(t)
+	t.Setenv("BCRYPT_COST", "4")
+	newTestMember(t, "bob", true)
+	return newTestMember(t, "alice", true)
+}
+
+func issueMemoryCode(t *testing.T, cn string) string {
+	t.Helper()
+	rec := serve(IssueMemoryCode, http.MethodPost, "/api/admin/memory-codes", `{"cn":"`+cn+`"}`, nil, map[string]interface{}{"user_cn": "admin"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("issue memory code: %d %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body.Code
+}
+
+func forgotPassword(cn, code string) int {
+	body := `{"cn":"` + cn + `","memory_code":"` + code + `","new_password":"` + newPassword + `"}`
+	return serve(ForgotPassword, http.MethodPost, "/api/forgot-password", body, nil, nil).Code
+}
+
+func TestMemoryCodeResetsPasswordOnce(t *testing.T) {
+	alice := setupMemoryCodeTest(t)
+	access, _ := newTestSession(t, alice, false)
+	code := issueMemoryCode(t, "alice")
+
+	// 备忘码只对签发给的成员有效
+	if status := forgotPassword("bob", code); status != http.StatusUnauthorized {
+		t.Fatalf("code used for another member: %d", status)
+	}
+	if status := forgotPassword("alice", code); status != http.StatusOK {
+		t.Fatalf("reset: %d", status)
+	}
+	var m models.ClubMember
+	config.DB.Where("cn = ?", "alice").First(&m)
+	if bcrypt.CompareHashAndPassword([]byte(m.Password), []byte(newPassword)) != nil {
+		t.Fatal("password not changed")
+	}
+	// 重置后原有会话失效，备忘码不能再次使用
+	if rec := callAuthorized(VerifyToken(okHandler), access); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("session after reset: %d", rec.Code)
+	}
+	if status := forgotPassword("alice", code); status != http.StatusUnauthorized {
+		t.Fatalf("reused code: %d", status)
+	}
+}
+
+func TestMemoryCodeRejectsSupersededAndExpiredCodes(t *testing.T) {
+	setupMemoryCodeTest(t)
+	first := issueMemoryCode(t, "alice")
+	second := issueMemoryCode(t, "alice")
+
+	// 重新签发后旧备忘码作废
+	if status := forgotPassword("alice", first); status != http.StatusUnauthorized {
+		t.Fatalf("superseded code: %d", status)
+	}
+	config.DB.Model(&models.MemoryCode{}).Where("cn = ?", "alice").Update("expires_at", time.Now().Add(-time.Minute))
+	if status := forgotPassword("alice", second); status != http.StatusUnauthorized {
+		t.Fatalf("expired code: %d", status)
+	}
+	var m models.ClubMember
+	config.DB.Where("cn = ?", "alice").First(&m)
+	if m.Password != "x" {
+		t.Fatal("password changed with an invalid code")
+	}
+}
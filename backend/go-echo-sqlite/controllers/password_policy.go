@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码策略配置：
+//   PASSWORD_MIN_LENGTH  最短长度，默认 8
+//   BCRYPT_COST          新密码哈希的 bcrypt cost，默认 12；登录成功时低于该值的哈希会被自动升级
+
+// legacyDefaultPassword 旧版备忘码重置使用的固定密码，不允许作为自选密码
+const legacyDefaultPassword = "0721"
+
+// bcrypt 只使用前 72 字节
+const maxPasswordBytes = 72
+
+var commonPasswords = map[string]struct{}{}
+
+func init() {
+	for _, p := range []string{
+		"123456", "1234567", "12345678", "123456789", "1234567890", "0123456789",
+		"111111", "11111111", "000000", "00000000", "666666", "88888888", "888888",
+		"123123", "123321", "654321", "112233", "121212", "520520", "5201314", "1314520",
+		"abc123", "abc12345", "abcd1234", "a123456", "a12345678", "aa123456", "qq123456",
+		"password", "password1", "password123", "passw0rd", "p@ssw0rd", "admin123", "admin888",
+		"qwerty", "qwerty123", "qwertyuiop", "1q2w3e4r", "1qaz2wsx", "zxcvbnm", "asdfghjkl",
+		"iloveyou", "woaini", "woaini1314", "letmein", "welcome", "monkey", "dragon", "sunshine",
+		"seventhcentury", "scvg123456",
+	} {
+		commonPasswords[p] = struct{}{}
+	}
+}
+
+func passwordMinLength() int { return envInt("PASSWORD_MIN_LENGTH", 8) }
+
+func passwordHashCost() int {
+	cost := envInt("BCRYPT_COST", 12)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// checkPasswordPolicy 校验自选密码：长度、常见弱密码、不得与 CN 相同、不得为 0721
+func checkPasswordPolicy(cn, password string) error {
+	if len([]rune(password)) < passwordMinLength() {
+		return fmt.Errorf("密码长度至少%d位", passwordMinLength())
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("密码长度不能超过%d字节", maxPasswordBytes)
+	}
+	if password == legacyDefaultPassword {
+		return errors.New("不能使用默认密码 0721")
+	}
+	if cn != "" && strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(cn)) {
+		return errors.New("密码不能与用户名相同")
+	}
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		return errors.New("密码过于常见，请更换")
+	}
+	return nil
+}
+
+// hashPassword 使用当前配置的 cost 生成密码哈希
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// passwordNeedsRehash 判断已有哈希的 cost 是否低于当前配置
+func passwordNeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost < passwordHashCost()
+}
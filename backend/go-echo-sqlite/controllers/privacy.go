@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 查看者的级别，与字段可见范围一一对应：级别不低于字段要求的查看者才能看到该字段
+const (
+	viewerVisitor = iota
+	viewerMember
+	viewerOfficer
+)
+
+var visibilityRank = map[string]int{
+	models.VisibilityPublic:   viewerVisitor,
+	models.VisibilityMembers:  viewerMember,
+	models.VisibilityOfficers: viewerOfficer,
+}
+
+// privacyView 一次请求中查看者对成员字段的可见性；为 nil 时不做过滤（审计快照等内部用途）
+type privacyView struct {
+	viewerCN string
+	level    int
+	settings map[string]map[string]string // CN -> 字段 -> 可见范围
+}
+
+// viewerLevel 拥有 members:write 权限的视为干部，其余已登录的社团成员视为成员，未登录或非成员视为访客
+func viewerLevel(c echo.Context) int {
+	if hasPermission(c, PermMembersWrite) {
+		return viewerOfficer
+	}
+	if isMember, _ := c.Get("is_member").(bool); isMember {
+		return viewerMember
+	}
+	return viewerVisitor
+}
+
+// newPrivacyView 按当前查看者身份与 cns 的隐私设置构造可见性判断
+func newPrivacyView(c echo.Context, cns ...string) *privacyView {
+	viewerCN, _ := c.Get("user_cn").(string)
+	view := &privacyView{
+		viewerCN: viewerCN,
+		level:    viewerLevel(c),
+		settings: make(map[string]map[string]string, len(cns)),
+	}
+	var rows []models.MemberPrivacy
+	if len(cns) > 0 {
+		config.DB.Where("cn IN ?", cns).Find(&rows)
+	}
+	for i := range rows {
+		view.settings[rows[i].CN] = rows[i].Visibility()
+	}
+	return view
+}
+
+// visible 查看者能否看到成员 cn 的 field 字段；本人始终可以看到自己的全部信息
+func (v *privacyView) visible(cn, field string) bool {
+	if v == nil || (v.viewerCN != "" && v.viewerCN == cn) {
+		return true
+	}
+	visibility, ok := v.settings[cn][field]
+	if !ok {
+		visibility = models.DefaultVisibility[field]
+	}
+	return v.level >= visibilityRank[visibility]
+}
+
+// hiddenFields fields 中成员 cn 对查看者隐藏的字段
+func (v *privacyView) hiddenFields(cn string, fields []string) []string {
+	var hidden []string
+	for _, field := range fields {
+		if !v.visible(cn, field) {
+			hidden = append(hidden, field)
+		}
+	}
+	return hidden
+}
+
+// redactMemberProfile 清空个人主页中对查看者隐藏的字段
+func redactMemberProfile(profile *models.MemberProfile, view *privacyView) {
+	fields := map[string]*string{
+		"avatar":              &profile.Avatar,
+		"bili_uid":            &profile.BiliUID,
+		"signature":           &profile.Signature,
+		"representative_work": &profile.RepresentativeWork,
+		"other":               &profile.Other,
+	}
+	for field, value := range fields {
+		if !view.visible(profile.CN, field) {
+			*value = ""
+		}
+	}
+}
+
+// privacyCondition 按非公开字段筛选或搜索成员时，只匹配查看者能看到该字段的成员，避免通过筛选结果反推隐藏的值
+// 返回追加到查询上的 CN 条件；查看者可以看到所有成员的该字段时返回空字符串
+func privacyCondition(c echo.Context, field string) (string, []string) {
+	viewerCN, _ := c.Get("user_cn").(string)
+	level := viewerLevel(c)
+	defaultVisible := level >= visibilityRank[models.DefaultVisibility[field]]
+
+	var rows []models.MemberPrivacy
+	config.DB.Find(&rows)
+	// 默认可见时排除设置了隐藏的成员，默认隐藏时只保留设置了可见的成员
+	var cns []string
+	for i := range rows {
+		visible := rows[i].CN == viewerCN || level >= visibilityRank[rows[i].Visibility()[field]]
+		if visible != defaultVisible {
+			cns = append(cns, rows[i].CN)
+		}
+	}
+	if defaultVisible {
+		if len(cns) == 0 {
+			return "", nil
+		}
+		return "cn NOT IN ?", cns
+	}
+	if viewerCN != "" {
+		cns = append(cns, viewerCN)
+	}
+	// 列表为空时 IN 条件不匹配任何成员
+	return "cn IN ?", cns
+}
+
+// canManagePrivacy 本人或拥有 members:write 权限的干部可以查看与修改隐私设置
+func canManagePrivacy(c echo.Context, cn string) bool {
+	actorCN, _ := c.Get("user_cn").(string)
+	return actorCN == cn || hasPermission(c, PermMembersWrite)
+}
+
+func privacyResponse(cn string, privacy *models.MemberPrivacy) echo.Map {
+	return echo.Map{
+		"cn":           cn,
+		"settings":     privacy.Visibility(),
+		"defaults":     models.DefaultVisibility,
+		"visibilities": models.Visibilities,
+	}
+}
+
+// GetMemberPrivacy 查看成员各字段的可见范围（未设置的字段为默认值）
+func GetMemberPrivacy(c echo.Context) error {
+	cn := c.Param("cn")
+	if !canManagePrivacy(c, cn) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权查看他人的隐私设置"})
+	}
+	if err := config.DB.Where("cn = ?", cn).First(&models.ClubMember{}).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+
+	var privacy models.MemberPrivacy
+	if err := config.DB.Where("cn = ?", cn).First(&privacy).Error; err != nil {
+		return c.JSON(http.StatusOK, privacyResponse(cn, nil))
+	}
+	return c.JSON(http.StatusOK, privacyResponse(cn, &privacy))
+}
+
+// UpdateMemberPrivacy 修改成员字段的可见范围，只需提交要修改的字段：
+// {"settings": {"sex": "officers", "bili_uid": "members"}}
+func UpdateMemberPrivacy(c echo.Context) error {
+	cn := c.Param("cn")
+	if !canManagePrivacy(c, cn) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "无权修改他人的隐私设置"})
+	}
+
+	var req struct {
+		Settings map[string]string `json:"settings"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if len(req.Settings) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "未提供可更新字段"})
+	}
+	for field, visibility := range req.Settings {
+		if _, ok := models.DefaultVisibility[field]; !ok {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "不支持设置可见范围的字段: " + field})
+		}
+		if !containsString(models.Visibilities, visibility) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "可见范围只能为 public、members 或 officers"})
+		}
+	}
+	if err := config.DB.Where("cn = ?", cn).First(&models.ClubMember{}).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+
+	var privacy models.MemberPrivacy
+	err := config.DB.Where("cn = ?", cn).First(&privacy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	before := privacy.Visibility()
+
+	// 只保存与默认值不同的字段，默认值调整后未设置过的成员随之生效
+	merged := privacy.Visibility()
+	for field, visibility := range req.Settings {
+		merged[field] = visibility
+	}
+	custom := map[string]string{}
+	for field, visibility := range merged {
+		if visibility != models.DefaultVisibility[field] {
+			custom[field] = visibility
+		}
+	}
+	raw, _ := json.Marshal(custom)
+	privacy.CN = cn
+	privacy.Settings = string(raw)
+	if err := config.DB.Save(&privacy).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "保存隐私设置失败"})
+	}
+
+	recordAudit(c, AuditPrivacyUpdate, "member", cn, before, privacy.Visibility())
+	// 知识库只收录公开字段
+	syncMembersAsync()
+	return c.JSON(http.StatusOK, privacyResponse(cn, &privacy))
+}
@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 内置角色
+const (
+	RoleGuest   = "guest"
+	RoleMember  = "member"
+	RoleOfficer = "officer"
+	RoleAdmin   = "admin"
+)
+
+// 命名权限
+const (
+	PermProfileWrite      = "profile:write"           // 编辑自己的个人主页
+	PermActivitiesWrite   = "activities:write"        // 发布活动
+	PermMembersWrite      = "members:write"           // 注册、修改、删除他人成员信息
+	PermRolesManage       = "roles:manage"            // 分配角色
+	PermSessionsManage    = "sessions:manage"         // 查看、撤销他人会话
+	PermSystemKeys        = "system:keys"             // 轮换签名密钥
+	PermAuditRead         = "audit:read"              // 查询审计日志
+	PermPasswordsReset    = "passwords:reset"         // 为成员签发密码重置令牌
+	PermAccountsUnlock    = "accounts:unlock"         // 解除账号锁定
+	PermMFAReset          = "mfa:reset"               // 重置成员的两步验证
+	PermInvitationsManage = "invitations:manage"      // 生成、作废注册邀请码
+	PermMembersApprove    = "members:approve"         // 审核入社申请
+	PermServiceAccounts   = "service_accounts:manage" // 管理服务账号及其令牌
+	PermTrashManage       = "trash:manage"            // 回收站恢复与彻底删除
+	PermRolloverManage    = "rollover:manage"         // 执行、撤销学年换届
+	PermVocabManage       = "vocab:manage"            // 维护受控词表
+)
+
+var permissionDescriptions = map[string]string{
+	PermProfileWrite:      "编辑自己的个人主页",
+	PermActivitiesWrite:   "发布活动",
+	PermMembersWrite:      "注册、修改、删除他人成员信息",
+	PermRolesManage:       "分配成员角色",
+	PermSessionsManage:    "查看、撤销成员会话",
+	PermSystemKeys:        "轮换JWT签名密钥",
+	PermAuditRead:         "查询审计日志",
+	PermPasswordsReset:    "为成员签发密码重置令牌",
+	PermAccountsUnlock:    "解除账号锁定",
+	PermMFAReset:          "重置成员的两步验证",
+	PermInvitationsManage: "生成、作废注册邀请码",
+	PermMembersApprove:    "审核访客的入社申请",
+	PermServiceAccounts:   "管理服务账号及其访问令牌",
+	PermTrashManage:       "查看回收站，恢复或彻底删除已删除的记录",
+	PermRolloverManage:    "预览、执行与撤销学年换届",
+	PermVocabManage:       "维护性别、职务、方向的受控词表并归一成员数据",
+}
+
+// defaultRoles 内置角色及其默认权限，启动时同步到数据库
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{RoleGuest, "访客", nil},
+	{RoleMember, "社团成员", []string{PermProfileWrite, PermActivitiesWrite}},
+	{RoleOfficer, "干部", []string{PermProfileWrite, PermActivitiesWrite, PermMembersWrite, PermInvitationsManage, PermMembersApprove}},
+	{RoleAdmin, "管理员", []string{PermProfileWrite, PermActivitiesWrite, PermMembersWrite, PermRolesManage, PermSessionsManage, PermSystemKeys, PermAuditRead, PermPasswordsReset, PermAccountsUnlock, PermMFAReset, PermInvitationsManage, PermMembersApprove, PermServiceAccounts, PermTrashManage, PermRolloverManage, PermVocabManage}},
+}
+
+// SeedRBAC 同步内置角色与权限；当还没有任何管理员时，按 MCP_ADMIN_CNS 为已注册成员分配管理员角色
+func SeedRBAC() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		perms := make(map[string]models.Permission)
+		for name, desc := range permissionDescriptions {
+			perm := models.Permission{Name: name}
+			if err := tx.Where(models.Permission{Name: name}).
+				Attrs(models.Permission{Description: desc}).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			perms[name] = perm
+		}
+
+		for _, def := range defaultRoles {
+			role := models.Role{Name: def.Name}
+			if err := tx.Where(models.Role{Name: def.Name}).
+				Attrs(models.Role{Description: def.Description}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+			rolePerms := make([]models.Permission, 0, len(def.Permissions))
+			for _, name := range def.Permissions {
+				rolePerms = append(rolePerms, perms[name])
+			}
+			if len(rolePerms) > 0 {
+				if err := tx.Model(&role).Association("Permissions").Append(rolePerms); err != nil {
+					return err
+				}
+			}
+		}
+
+		var adminCount int64
+		if err := tx.Model(&models.MemberRole{}).Where("role_name = ?", RoleAdmin).Count(&adminCount).Error; err != nil {
+			return err
+		}
+		if adminCount > 0 {
+			return nil
+		}
+
+		seeded := 0
+		for _, cn := range mcpAdminBootstrapCNs() {
+			var count int64
+			tx.Model(&models.ClubMember{}).Where("cn = ?", cn).Count(&count)
+			if count == 0 {
+				fmt.Printf("⚠ MCP_ADMIN_CNS 中的成员 %s 尚未注册，跳过管理员初始化\n", cn)
+				continue
+			}
+			if err := tx.Create(&models.MemberRole{CN: cn, RoleName: RoleAdmin, GrantedBy: "MCP_ADMIN_CNS"}).Error; err != nil {
+				return err
+			}
+			// 无邀请码注册的账号是访客，初始管理员需同时成为社团成员
+			if err := tx.Model(&models.ClubMember{}).Where("cn = ?", cn).Update("is_member", true).Error; err != nil {
+				return err
+			}
+			var member models.ClubMember
+			if err := tx.Select("cn", "status").Where("cn = ?", cn).First(&member).Error; err != nil {
+				return err
+			}
+			if member.Status == models.MemberStatusApplicant {
+				if err := recordStatusChange(tx, cn, member.Status, models.MemberStatusActive, "初始管理员", "MCP_ADMIN_CNS"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&models.MembershipApplication{}).
+				Where("cn = ? AND status = ?", cn, ApplicationPending).
+				Updates(map[string]interface{}{"status": ApplicationApproved, "reviewed_by": "MCP_ADMIN_CNS"}).Error; err != nil {
+				return err
+			}
+			seeded++
+		}
+		if seeded == 0 {
+			fmt.Println("⚠ 警告: 当前没有任何管理员，请设置 MCP_ADMIN_CNS 为已注册成员后重启")
+		}
+		return nil
+	})
+}
+
+// memberRoles 返回成员的有效角色；未分配角色时按 IsMember 取 member 或 guest
+func memberRoles(member models.ClubMember) ([]string, error) {
+	var assigned []models.MemberRole
+	if err := config.DB.Where("cn = ?", member.CN).Find(&assigned).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(assigned)+1)
+	for _, a := range assigned {
+		roles = append(roles, a.RoleName)
+	}
+	if len(roles) == 0 {
+		if member.IsMember {
+			roles = append(roles, RoleMember)
+		} else {
+			roles = append(roles, RoleGuest)
+		}
+	}
+	return roles, nil
+}
+
+// rolePermissions 返回一组角色拥有的全部权限
+func rolePermissions(roles []string) ([]string, error) {
+	var perms []string
+	err := config.DB.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name IN ? AND roles.deleted_at IS NULL AND permissions.deleted_at IS NULL", roles).
+		Pluck("permissions.name", &perms).Error
+	return perms, err
+}
+
+// memberAccess 计算写入 token 的角色与权限
+func memberAccess(member models.ClubMember) ([]string, []string, error) {
+	roles, err := memberRoles(member)
+	if err != nil {
+		return nil, nil, err
+	}
+	perms, err := rolePermissions(roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, perms, nil
+}
+
+// hasPermission 判断当前请求的 token 是否包含指定权限
+func hasPermission(c echo.Context, perm string) bool {
+	perms, _ := c.Get("permissions").([]string)
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission 需要指定权限的中间件
+func RequirePermission(perm string) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return VerifyToken(func(c echo.Context) error {
+			if !hasPermission(c, perm) {
+				if required, _ := c.Get("mfa_required").(bool); required {
+					return c.JSON(http.StatusForbidden, echo.Map{"error": "管理员操作需要通过两步验证的会话，请重新登录并完成两步验证"})
+				}
+				return c.JSON(http.StatusForbidden, echo.Map{"error": "权限不足"})
+			}
+			return next(c)
+		})
+	}
+}
+
+// ListRoles 列出全部角色及其权限
+func ListRoles(c echo.Context) error {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(roles))
+	for _, r := range roles {
+		perms := make([]string, 0, len(r.Permissions))
+		for _, p := range r.Permissions {
+			perms = append(perms, p.Name)
+		}
+		items = append(items, echo.Map{
+			"name":        r.Name,
+			"description": r.Description,
+			"permissions": perms,
+		})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetMemberRoles 查看成员的角色分配
+func GetMemberRoles(c echo.Context) error {
+	cn := c.Param("cn")
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+
+	var assigned []models.MemberRole
+	if err := config.DB.Where("cn = ?", cn).Find(&assigned).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	roles, perms, err := memberAccess(member)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	assignments := make([]echo.Map, 0, len(assigned))
+	for _, a := range assigned {
+		assignments = append(assignments, echo.Map{
+			"role":       a.RoleName,
+			"granted_by": a.GrantedBy,
+			"granted_at": a.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"cn":          cn,
+		"assignments": assignments,
+		"roles":       roles,
+		"permissions": perms,
+	})
+}
+
+// AssignMemberRole 为成员分配角色
+func AssignMemberRole(c echo.Context) error {
+	type AssignRequest struct {
+		Role string `json:"role"`
+	}
+
+	cn := c.Param("cn")
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req AssignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+	var role models.Role
+	if err := config.DB.Where("name = ?", req.Role).First(&role).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "角色不存在"})
+	}
+
+	var count int64
+	config.DB.Model(&models.MemberRole{}).Where("cn = ? AND role_name = ?", cn, role.Name).Count(&count)
+	if count > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "该成员已拥有此角色"})
+	}
+
+	// 权限写在访问 token 中，角色变更后撤销该成员的会话，重新登录后按新角色签发
+	assignment := models.MemberRole{CN: cn, RoleName: role.Name, GrantedBy: actorCN}
+	var revoked int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+		n, err := revokeSessionsForCN(tx, cn)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "分配角色失败"})
+	}
+	recordAudit(c, AuditRoleAssign, "member", cn, nil, echo.Map{"role": role.Name, "sessions_revoked": revoked})
+	return c.JSON(http.StatusCreated, echo.Map{"message": "角色分配成功", "cn": cn, "role": role.Name})
+}
+
+// RemoveMemberRole 移除成员的角色
+func RemoveMemberRole(c echo.Context) error {
+	cn := c.Param("cn")
+	roleName := c.Param("role")
+	actorCN, _ := c.Get("user_cn").(string)
+
+	// 防止管理员误操作导致系统中没有管理员
+	if roleName == RoleAdmin {
+		var adminCount int64
+		config.DB.Model(&models.MemberRole{}).Where("role_name = ?", RoleAdmin).Count(&adminCount)
+		if adminCount <= 1 {
+			return c.JSON(http.StatusConflict, echo.Map{"error": "不能移除最后一位管理员"})
+		}
+		if actorCN == cn {
+			return c.JSON(http.StatusConflict, echo.Map{"error": "不能移除自己的管理员角色"})
+		}
+	}
+
+	var revoked int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("cn = ? AND role_name = ?", cn, roleName).Delete(&models.MemberRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 被移除的权限仍写在已签发的访问 token 中，需撤销会话使其立即失效
+		n, err := revokeSessionsForCN(tx, cn)
+		revoked = n
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "该成员没有此角色"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "移除角色失败"})
+	}
+	recordAudit(c, AuditRoleRemove, "member", cn, echo.Map{"role": roleName}, echo.Map{"sessions_revoked": revoked})
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupRBACTest(t *testing.T) {
+	t.Helper()
+	newTestDB(t)
+	t.Setenv("MCP_ADMIN_CNS", "")
+	t.Setenv("TOTP_REQUIRED_FOR_ADMINS", "")
+	if err := SeedRBAC(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var okHandler = func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+func changeRole(h echo.HandlerFunc, method, cn, role, actor string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if method == http.MethodPost {
+		req = httptest.NewRequest(method, "/", strings.NewReader(`{"role":"`+role+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	} else {
+		req = httptest.NewRequest(method, "/", nil)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("cn", "role")
+	c.SetParamValues(cn, role)
+	c.Set("user_cn", actor)
+	h(c)
+	return rec
+}
+
+func TestRequirePermissionFollowsRoles(t *testing.T) {
+	setupRBACTest(t)
+	guarded := RequirePermission(PermMembersWrite)(okHandler)
+	cases := []struct {
+		member models.ClubMember
+		want   int
+	}{
+		{newTestMember(t, "guest", false), http.StatusForbidden},
+		{newTestMember(t, "member", true), http.StatusForbidden},
+		{newTestMember(t, "officer", true, RoleOfficer), http.StatusOK},
+		{newTestMember(t, "admin", true, RoleAdmin), http.StatusOK},
+	}
+	for _, tc := range cases {
+		token, _ := newTestSession(t, tc.member, false)
+		if rec := callAuthorized(guarded, token); rec.Code != tc.want {
+			t.Errorf("%s: %d, want %d", tc.member.CN, rec.Code, tc.want)
+		}
+	}
+	if rec := callAuthorized(guarded, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: %d", rec.Code)
+	}
+}
+
+func TestRequirePermissionAdminNeedsMFA(t *testing.T) {
+	setupRBACTest(t)
+	t.Setenv("TOTP_REQUIRED_FOR_ADMINS", "true")
+	admin := newTestMember(t, "admin", true, RoleAdmin)
+	guarded := RequirePermission(PermRolesManage)(okHandler)
+
+	token, _ := newTestSession(t, admin, false)
+	if rec := callAuthorized(guarded, token); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "两步验证") {
+		t.Fatalf("admin without MFA: %d %s", rec.Code, rec.Body)
+	}
+	token, _ = newTestSession(t, admin, true)
+	if rec := callAuthorized(guarded, token); rec.Code != http.StatusOK {
+		t.Fatalf("admin with MFA: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRoleChangesRevokeSessions(t *testing.T) {
+	setupRBACTest(t)
+	newTestMember(t, "admin", true, RoleAdmin)
+	officer := newTestMember(t, "officer", true, RoleOfficer)
+	member := newTestMember(t, "member", true)
+	guarded := RequirePermission(PermMembersWrite)(okHandler)
+
+	// 降级后原访问 token 立即失效，不再保留旧权限
+	officerToken, _ := newTestSession(t, officer, false)
+	if rec := changeRole(RemoveMemberRole, http.MethodDelete, "officer", RoleOfficer, "admin"); rec.Code != http.StatusNoContent {
+		t.Fatalf("remove role: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(guarded, officerToken); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("demoted officer token: %d %s", rec.Code, rec.Body)
+	}
+
+	// 授予角色同样要求重新登录，新会话带上新权限
+	memberToken, _ := newTestSession(t, member, false)
+	if rec := changeRole(AssignMemberRole, http.MethodPost, "member", RoleOfficer, "admin"); rec.Code != http.StatusCreated {
+		t.Fatalf("assign role: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(guarded, memberToken); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token issued before promotion: %d %s", rec.Code, rec.Body)
+	}
+	memberToken, _ = newTestSession(t, member, false)
+	if rec := callAuthorized(guarded, memberToken); rec.Code != http.StatusOK {
+		t.Fatalf("token issued after promotion: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRemoveMemberRoleKeepsLastAdmin(t *testing.T) {
+	setupRBACTest(t)
+	newTestMember(t, "admin", true, RoleAdmin)
+	newTestMember(t, "other", true, RoleAdmin)
+
+	if rec := changeRole(RemoveMemberRole, http.MethodDelete, "admin", RoleAdmin, "admin"); rec.Code != http.StatusConflict {
+		t.Fatalf("removed own admin role: %d %s", rec.Code, rec.Body)
+	}
+	if rec := changeRole(RemoveMemberRole, http.MethodDelete, "other", RoleAdmin, "admin"); rec.Code != http.StatusNoContent {
+		t.Fatalf("remove other admin: %d %s", rec.Code, rec.Body)
+	}
+	if rec := changeRole(RemoveMemberRole, http.MethodDelete, "admin", RoleAdmin, "other"); rec.Code != http.StatusConflict {
+		t.Fatalf("removed the last admin: %d %s", rec.Code, rec.Body)
+	}
+	var count int64
+	config.DB.Model(&models.MemberRole{}).Where("role_name = ?", RoleAdmin).Count(&count)
+	if count != 1 {
+		t.Fatalf("%d admins left", count)
+	}
+}
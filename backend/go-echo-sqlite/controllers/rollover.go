@@ -0,0 +1,652 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 学年换届配置：
+//   ROLLOVER_GRADUATION_YEARS  入学后第几个学年开始时毕业，默认 4（2021 级在 2025 学年换届时毕业）
+//   ROLLOVER_DATE              新学年开始日期 MM-DD，默认 09-01
+//   ROLLOVER_AUTO              true 时到达 ROLLOVER_DATE 后自动换届（每个学年只执行一次）
+//   ROLLOVER_AUTO_WINDOW       自动换届只在 ROLLOVER_DATE 之后的这段时间内执行，默认 168h（7 天）；
+//                              错过窗口（如学期中途才开启自动换届）需要管理员手动执行
+//   ROLLOVER_CHECK_INTERVAL    自动换届的检查间隔，默认 1h
+//   ROLLOVER_KEEP_POSITIONS    换届时保留的职务（逗号分隔，如 组员），请求未指定时使用
+
+// 换届规则
+const (
+	ruleGraduate          = "graduate"
+	ruleVacatePositions   = "vacate_positions"
+	ruleActivateNewCohort = "activate_new_cohort"
+)
+
+var (
+	errRolloverApplied    = errors.New("该学年已执行过换届，请先撤销")
+	errRolloverRolledBack = errors.New("该次换届已撤销")
+	errRolloverNotLatest  = errors.New("只能撤销最近一次换届")
+	errRolloverConflict   = errors.New("部分成员在换届后被修改过，确认覆盖请使用 force=true")
+)
+
+func rolloverGraduationYears() int { return envInt("ROLLOVER_GRADUATION_YEARS", 4) }
+
+// rolloverDate 新学年开始的月、日
+func rolloverDate() (time.Month, int) {
+	if t, err := time.Parse("01-02", strings.TrimSpace(os.Getenv("ROLLOVER_DATE"))); err == nil {
+		return t.Month(), t.Day()
+	}
+	return time.September, 1
+}
+
+// academicYearStart 给定时间所在学年的开始时间
+func academicYearStart(now time.Time) time.Time {
+	month, day := rolloverDate()
+	start := time.Date(now.Year(), month, day, 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		return start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+// currentAcademicYear 给定时间所在学年的起始年份
+func currentAcademicYear(now time.Time) string {
+	return strconv.Itoa(academicYearStart(now).Year())
+}
+
+// graduationSteps 毕业按状态流程先转为已毕业再转为校友，返回依次变更到的状态；
+// 不能按 statusTransitions 转为已毕业的成员（如申请中、已是校友）返回 nil
+func graduationSteps(from string) []string {
+	if from != models.MemberStatusGraduated {
+		if _, ok := findStatusTransition(from, models.MemberStatusGraduated); !ok {
+			return nil
+		}
+	}
+	if _, ok := findStatusTransition(models.MemberStatusGraduated, models.MemberStatusAlumni); !ok {
+		return nil
+	}
+	if from == models.MemberStatusGraduated {
+		return []string{models.MemberStatusAlumni}
+	}
+	return []string{models.MemberStatusGraduated, models.MemberStatusAlumni}
+}
+
+// rolloverStatusSteps 换届中状态从 from 变为 to 时依次记录的状态，毕业经由已毕业
+func rolloverStatusSteps(from, to string) []string {
+	if to == models.MemberStatusAlumni {
+		if steps := graduationSteps(from); steps != nil {
+			return steps
+		}
+	}
+	return []string{to}
+}
+
+// rolloverRules 本次换届启用的规则
+type rolloverRules struct {
+	Graduate          bool     `json:"graduate"`            // 毕业届成员转为校友
+	VacatePositions   bool     `json:"vacate_positions"`    // 清空职务，并为上一学年留下任职记录
+	ActivateNewCohort bool     `json:"activate_new_cohort"` // 新一届待审核的申请者转为在役成员
+	KeepPositions     []string `json:"keep_positions"`      // 清空职务时保留的职务
+}
+
+// rolloverMemberState 换届涉及的成员字段
+type rolloverMemberState struct {
+	Position string `json:"position"`
+	Status   string `json:"status"`
+	IsMember bool   `json:"is_member"`
+}
+
+type rolloverMember struct {
+	CN     string              `json:"cn"`
+	Before rolloverMemberState `json:"before"`
+	After  rolloverMemberState `json:"after"`
+}
+
+// rolloverSnapshot 回滚所需的全部信息
+type rolloverSnapshot struct {
+	Members        []rolloverMember `json:"members"`
+	TenureIDs      []uint           `json:"tenure_ids"`
+	ApplicationIDs []uint           `json:"application_ids"`
+}
+
+// rolloverChange 报告中的一项变更
+type rolloverChange struct {
+	CN     string `json:"cn"`
+	Rule   string `json:"rule"`
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type rolloverPlan struct {
+	AcademicYear string
+	Rules        rolloverRules
+	Members      []rolloverMember
+	Changes      []rolloverChange
+	Tenures      []models.Tenure
+	Applications []models.MembershipApplication
+}
+
+// graduatingCohort 入学年份达到毕业年限的成员
+func graduatingCohort(enrollYear, academicYear string) bool {
+	y, err := strconv.Atoi(enrollYear)
+	if err != nil {
+		return false
+	}
+	target, _ := strconv.Atoi(academicYear)
+	return y+rolloverGraduationYears() <= target
+}
+
+// planRollover 计算换届将产生的变更，预览与执行共用
+func planRollover(tx *gorm.DB, academicYear string, rules rolloverRules, actorCN string) (*rolloverPlan, error) {
+	plan := &rolloverPlan{AcademicYear: academicYear, Rules: rules, Members: []rolloverMember{}, Changes: []rolloverChange{}}
+	year, _ := strconv.Atoi(academicYear)
+	prevYear := strconv.Itoa(year - 1)
+
+	var members []models.ClubMember
+	if err := tx.Order("cn asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	// 已通过组织架构任命到新学年的成员，职务改为新学年的职位，不再清空
+	var assigned []models.Tenure
+	if err := tx.Where("academic_year = ? AND position_id IS NOT NULL", academicYear).Order("id asc").Find(&assigned).Error; err != nil {
+		return nil, err
+	}
+	newPositions := make(map[string]string, len(assigned))
+	for _, t := range assigned {
+		if _, ok := newPositions[t.CN]; !ok {
+			newPositions[t.CN] = t.Position
+		}
+	}
+
+	pending := make(map[string]models.MembershipApplication)
+	if rules.ActivateNewCohort {
+		var apps []models.MembershipApplication
+		if err := tx.Where("status = ?", ApplicationPending).Order("id asc").Find(&apps).Error; err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			if _, ok := pending[app.CN]; !ok {
+				pending[app.CN] = app
+			}
+		}
+	}
+
+	for _, m := range members {
+		before := rolloverMemberState{Position: m.Position, Status: m.Status, IsMember: m.IsMember}
+		after := before
+		change := func(rule, field, from, to string) {
+			plan.Changes = append(plan.Changes, rolloverChange{CN: m.CN, Rule: rule, Field: field, Before: from, After: to})
+		}
+
+		if rules.Graduate && graduatingCohort(m.Year, academicYear) && m.IsMember && graduationSteps(m.Status) != nil {
+			after.Status = models.MemberStatusAlumni
+			change(ruleGraduate, "status", models.MemberStatusLabel(before.Status), models.MemberStatusLabel(after.Status))
+		}
+
+		newPosition, reassigned := newPositions[m.CN]
+		if rules.VacatePositions && reassigned && m.Position != newPosition {
+			after.Position = newPosition
+			change(ruleVacatePositions, "position", before.Position, newPosition)
+		}
+		if rules.VacatePositions && !reassigned && m.Position != "" && !containsString(rules.KeepPositions, m.Position) {
+			after.Position = ""
+			change(ruleVacatePositions, "position", before.Position, "")
+		}
+		// 上一学年的职务留下任职记录；已任命到新学年且职务未变的成员不归档
+		if rules.VacatePositions && after.Position != before.Position && m.Position != "" {
+			var count int64
+			if err := tx.Model(&models.Tenure{}).
+				Where("cn = ? AND academic_year = ? AND position = ?", m.CN, prevYear, m.Position).
+				Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				plan.Tenures = append(plan.Tenures, models.Tenure{
+					CN:           m.CN,
+					AcademicYear: prevYear,
+					Position:     m.Position,
+					Direction:    m.Direction,
+					Status:       m.Status,
+					Note:         academicYear + " 学年换届自动归档",
+					CreatedBy:    actorCN,
+				})
+			}
+		}
+
+		if app, ok := pending[m.CN]; ok && m.Year == academicYear && m.Status == models.MemberStatusApplicant {
+			after.Status = models.MemberStatusActive
+			after.IsMember = true
+			change(ruleActivateNewCohort, "status", models.MemberStatusLabel(before.Status), models.MemberStatusLabel(after.Status))
+			plan.Applications = append(plan.Applications, app)
+		}
+
+		if after != before {
+			plan.Members = append(plan.Members, rolloverMember{CN: m.CN, Before: before, After: after})
+		}
+	}
+	return plan, nil
+}
+
+// applyRollover 在同一事务内执行换届并保存快照
+func applyRollover(academicYear string, rules rolloverRules, actorCN string) (*models.Rollover, *rolloverPlan, error) {
+	var rollover models.Rollover
+	var plan *rolloverPlan
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Rollover{}).
+			Where("academic_year = ? AND rolled_back_at IS NULL", academicYear).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errRolloverApplied
+		}
+
+		var err error
+		plan, err = planRollover(tx, academicYear, rules, actorCN)
+		if err != nil {
+			return err
+		}
+
+		reason := academicYear + " 学年换届"
+		snapshot := rolloverSnapshot{Members: plan.Members, TenureIDs: []uint{}, ApplicationIDs: []uint{}}
+		for _, pm := range plan.Members {
+			updates := map[string]interface{}{}
+			if pm.After.Position != pm.Before.Position {
+				updates["position"] = pm.After.Position
+			}
+			if pm.After.IsMember != pm.Before.IsMember {
+				updates["is_member"] = pm.After.IsMember
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&models.ClubMember{}).Where("cn = ?", pm.CN).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			from := pm.Before.Status
+			if pm.After.Status != from {
+				for _, to := range rolloverStatusSteps(from, pm.After.Status) {
+					if err := recordStatusChange(tx, pm.CN, from, to, reason, actorCN); err != nil {
+						return err
+					}
+					from = to
+				}
+			}
+		}
+		for i := range plan.Tenures {
+			if err := tx.Create(&plan.Tenures[i]).Error; err != nil {
+				return err
+			}
+			snapshot.TenureIDs = append(snapshot.TenureIDs, plan.Tenures[i].ID)
+		}
+		now := time.Now()
+		for _, app := range plan.Applications {
+			if err := tx.Model(&models.MembershipApplication{}).
+				Where("id = ? AND status = ?", app.ID, ApplicationPending).
+				Updates(map[string]interface{}{
+					"status":      ApplicationApproved,
+					"reason":      reason,
+					"reviewed_by": actorCN,
+					"reviewed_at": &now,
+				}).Error; err != nil {
+				return err
+			}
+			snapshot.ApplicationIDs = append(snapshot.ApplicationIDs, app.ID)
+		}
+
+		rulesJSON, _ := json.Marshal(rules)
+		snapshotJSON, _ := json.Marshal(snapshot)
+		reportJSON, _ := json.Marshal(plan.Changes)
+		rollover = models.Rollover{
+			AcademicYear: academicYear,
+			Rules:        string(rulesJSON),
+			Snapshot:     string(snapshotJSON),
+			Report:       string(reportJSON),
+			AppliedBy:    actorCN,
+		}
+		return tx.Create(&rollover).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	syncMembersAsync()
+	return &rollover, plan, nil
+}
+
+// rollbackRollover 按快照恢复换届前的状态；force 为 false 时，换届后被修改过的成员会阻止回滚
+func rollbackRollover(id string, force bool, actorCN string) (*models.Rollover, []string, []string, error) {
+	var rollover models.Rollover
+	conflicts, skipped := []string{}, []string{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&rollover, id).Error; err != nil {
+			return err
+		}
+		if rollover.RolledBackAt != nil {
+			return errRolloverRolledBack
+		}
+		var later int64
+		if err := tx.Model(&models.Rollover{}).
+			Where("id > ? AND rolled_back_at IS NULL", rollover.ID).
+			Count(&later).Error; err != nil {
+			return err
+		}
+		if later > 0 {
+			return errRolloverNotLatest
+		}
+
+		var snapshot rolloverSnapshot
+		if err := json.Unmarshal([]byte(rollover.Snapshot), &snapshot); err != nil {
+			return err
+		}
+
+		restore := make([]rolloverMember, 0, len(snapshot.Members))
+		for _, sm := range snapshot.Members {
+			var m models.ClubMember
+			if err := tx.Where("cn = ?", sm.CN).First(&m).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// 换届后被删除或改名的成员无法恢复
+					skipped = append(skipped, sm.CN)
+					continue
+				}
+				return err
+			}
+			current := rolloverMemberState{Position: m.Position, Status: m.Status, IsMember: m.IsMember}
+			if current != sm.After {
+				conflicts = append(conflicts, sm.CN)
+			}
+			sm.After = current
+			restore = append(restore, sm)
+		}
+		if len(conflicts) > 0 && !force {
+			return errRolloverConflict
+		}
+
+		reason := "撤销 " + rollover.AcademicYear + " 学年换届"
+		for _, sm := range restore {
+			if err := tx.Model(&models.ClubMember{}).Where("cn = ?", sm.CN).Updates(map[string]interface{}{
+				"position":  sm.Before.Position,
+				"is_member": sm.Before.IsMember,
+			}).Error; err != nil {
+				return err
+			}
+			if sm.After.Status != sm.Before.Status {
+				if err := recordStatusChange(tx, sm.CN, sm.After.Status, sm.Before.Status, reason, actorCN); err != nil {
+					return err
+				}
+			}
+		}
+		if len(snapshot.TenureIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", snapshot.TenureIDs).Delete(&models.Tenure{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.ApplicationIDs) > 0 {
+			if err := tx.Model(&models.MembershipApplication{}).
+				Where("id IN ? AND status = ?", snapshot.ApplicationIDs, ApplicationApproved).
+				Updates(map[string]interface{}{
+					"status":      ApplicationPending,
+					"reason":      "",
+					"reviewed_by": "",
+					"reviewed_at": nil,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		rollover.RolledBackAt = &now
+		rollover.RolledBackBy = actorCN
+		return tx.Model(&rollover).Updates(map[string]interface{}{
+			"rolled_back_at": &now,
+			"rolled_back_by": actorCN,
+		}).Error
+	})
+	if err != nil {
+		return nil, conflicts, skipped, err
+	}
+	syncMembersAsync()
+	return &rollover, conflicts, skipped, nil
+}
+
+// defaultRolloverRules 自动换届及请求未指定规则时使用全部规则
+func defaultRolloverRules() rolloverRules {
+	return rolloverRules{
+		Graduate:          true,
+		VacatePositions:   true,
+		ActivateNewCohort: true,
+		KeepPositions:     splitQueryValues(os.Getenv("ROLLOVER_KEEP_POSITIONS")),
+	}
+}
+
+// bindRolloverRequest 解析 {academic_year, rules}，学年默认为当前学年
+func bindRolloverRequest(c echo.Context) (string, rolloverRules, error) {
+	type RolloverRequest struct {
+		AcademicYear string         `json:"academic_year"`
+		Rules        *rolloverRules `json:"rules"`
+	}
+	var req RolloverRequest
+	if err := c.Bind(&req); err != nil {
+		return "", rolloverRules{}, errors.New("请求格式错误")
+	}
+	year := strings.TrimSpace(req.AcademicYear)
+	if year == "" {
+		year = currentAcademicYear(time.Now())
+	}
+	if !validAcademicYear(year) {
+		return "", rolloverRules{}, errors.New("学年格式错误，应为四位年份")
+	}
+	rules := defaultRolloverRules()
+	if req.Rules != nil {
+		rules = *req.Rules
+		if rules.KeepPositions == nil {
+			rules.KeepPositions = defaultRolloverRules().KeepPositions
+		}
+	}
+	return year, rules, nil
+}
+
+func rolloverSummary(plan *rolloverPlan) echo.Map {
+	counts := map[string]int{ruleGraduate: 0, ruleVacatePositions: 0, ruleActivateNewCohort: 0}
+	for _, ch := range plan.Changes {
+		counts[ch.Rule]++
+	}
+	return echo.Map{
+		"members":       len(plan.Members),
+		"graduated":     counts[ruleGraduate],
+		"vacated":       counts[ruleVacatePositions],
+		"activated":     counts[ruleActivateNewCohort],
+		"tenures_added": len(plan.Tenures),
+	}
+}
+
+func rolloverResponse(r models.Rollover) echo.Map {
+	return echo.Map{
+		"id":             r.ID,
+		"academic_year":  r.AcademicYear,
+		"rules":          rawAuditJSON(r.Rules),
+		"applied_by":     r.AppliedBy,
+		"applied_at":     r.CreatedAt,
+		"rolled_back_at": r.RolledBackAt,
+		"rolled_back_by": r.RolledBackBy,
+	}
+}
+
+// PreviewRollover 预览换届结果，不写入任何数据
+func PreviewRollover(c echo.Context) error {
+	year, rules, err := bindRolloverRequest(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	actorCN, _ := c.Get("user_cn").(string)
+	plan, err := planRollover(config.DB, year, rules, actorCN)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"academic_year": year,
+		"rules":         rules,
+		"summary":       rolloverSummary(plan),
+		"changes":       plan.Changes,
+	})
+}
+
+// ApplyRollover 执行换届，返回变更报告；报告可随后通过 /report 下载
+func ApplyRollover(c echo.Context) error {
+	year, rules, err := bindRolloverRequest(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	actorCN, _ := c.Get("user_cn").(string)
+
+	rollover, plan, err := applyRollover(year, rules, actorCN)
+	if err != nil {
+		if errors.Is(err, errRolloverApplied) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "换届失败，未修改任何数据"})
+	}
+
+	summary := rolloverSummary(plan)
+	recordAudit(c, AuditRolloverApply, "rollover", strconv.Itoa(int(rollover.ID)), nil, echo.Map{"academic_year": year, "rules": rules, "summary": summary})
+	resp := rolloverResponse(*rollover)
+	resp["summary"] = summary
+	resp["changes"] = plan.Changes
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListRollovers 列出历次换届
+func ListRollovers(c echo.Context) error {
+	var rollovers []models.Rollover
+	if err := config.DB.Order("id desc").Find(&rollovers).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	items := make([]echo.Map, 0, len(rollovers))
+	for _, r := range rollovers {
+		items = append(items, rolloverResponse(r))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetRolloverReport 下载换届报告，?format=csv|json（默认 csv）
+func GetRolloverReport(c echo.Context) error {
+	var rollover models.Rollover
+	if err := config.DB.First(&rollover, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "换届记录不存在"})
+	}
+	var changes []rolloverChange
+	if err := json.Unmarshal([]byte(rollover.Report), &changes); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "换届报告已损坏"})
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	filename := fmt.Sprintf("rollover_%s_%d.%s", rollover.AcademicYear, rollover.ID, format)
+	switch format {
+	case "json":
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		resp := rolloverResponse(rollover)
+		resp["changes"] = changes
+		return c.JSON(http.StatusOK, resp)
+	case "csv":
+		columns := []exportColumn{{"cn", "成员"}, {"rule", "规则"}, {"field", "字段"}, {"before", "换届前"}, {"after", "换届后"}}
+		records := make([]map[string]string, 0, len(changes))
+		for _, ch := range changes {
+			records = append(records, map[string]string{"cn": ch.CN, "rule": ch.Rule, "field": ch.Field, "before": ch.Before, "after": ch.After})
+		}
+		data, err := memberExportCSV(columns, records)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成 CSV 失败"})
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+	default:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format 只能为 csv 或 json"})
+	}
+}
+
+// RollbackRollover 按快照撤销一次换届，?force=true 时覆盖换届后的修改
+func RollbackRollover(c echo.Context) error {
+	actorCN, _ := c.Get("user_cn").(string)
+	force, _ := strconv.ParseBool(c.QueryParam("force"))
+
+	rollover, conflicts, skipped, err := rollbackRollover(c.Param("id"), force, actorCN)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "换届记录不存在"})
+		case errors.Is(err, errRolloverConflict):
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error(), "conflicts": conflicts})
+		case errors.Is(err, errRolloverRolledBack), errors.Is(err, errRolloverNotLatest):
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "撤销失败，未修改任何数据"})
+	}
+
+	recordAudit(c, AuditRolloverRollback, "rollover", c.Param("id"), nil, echo.Map{
+		"academic_year": rollover.AcademicYear,
+		"overwritten":   conflicts,
+		"skipped":       skipped,
+	})
+	resp := rolloverResponse(*rollover)
+	resp["overwritten"] = conflicts
+	resp["skipped"] = skipped
+	return c.JSON(http.StatusOK, resp)
+}
+
+// runScheduledRollover 在新学年开始后的 ROLLOVER_AUTO_WINDOW 内，且该学年从未换届（包括已撤销的）时自动执行
+func runScheduledRollover() {
+	now := time.Now()
+	if now.Sub(academicYearStart(now)) >= envDuration("ROLLOVER_AUTO_WINDOW", 7*24*time.Hour) {
+		return
+	}
+	year := currentAcademicYear(now)
+	var count int64
+	if err := config.DB.Model(&models.Rollover{}).Where("academic_year = ?", year).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	rules := defaultRolloverRules()
+	rollover, plan, err := applyRollover(year, rules, "system")
+	if err != nil {
+		fmt.Printf("自动学年换届失败: %v\n", err)
+		return
+	}
+	summary := rolloverSummary(plan)
+	config.DB.Create(&models.AuditLog{
+		ActorCN:    "system",
+		Action:     AuditRolloverApply,
+		TargetType: "rollover",
+		Target:     strconv.Itoa(int(rollover.ID)),
+		After:      auditJSON(toAuditMap(echo.Map{"academic_year": year, "rules": rules, "summary": summary})),
+	})
+	fmt.Printf("✓ 已自动执行 %s 学年换届，涉及 %d 名成员\n", year, len(plan.Members))
+}
+
+// StartRolloverSchedule ROLLOVER_AUTO 开启时定时检查是否需要自动换届
+func StartRolloverSchedule() {
+	if !envBool("ROLLOVER_AUTO") {
+		return
+	}
+	interval := envDuration("ROLLOVER_CHECK_INTERVAL", time.Hour)
+	go func() {
+		runScheduledRollover()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runScheduledRollover()
+		}
+	}()
+}
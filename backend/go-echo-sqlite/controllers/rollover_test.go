@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+	"time"
+)
+
+func setupRolloverTest(t *testing.T) {
+	t.Helper()
+	newTestDB(t)
+	t.Setenv("ROLLOVER_GRADUATION_YEARS", "4")
+	t.Setenv("ROLLOVER_KEEP_POSITIONS", "")
+}
+
+func createRolloverMember(t *testing.T, m models.ClubMember) {
+	t.Helper()
+	m.Password = "x"
+	m.IsMember = true
+	if err := config.DB.Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRolloverGraduatesThroughLifecycle(t *testing.T) {
+	setupRolloverTest(t)
+	createRolloverMember(t, models.ClubMember{CN: "senior", Year: "2021", Status: models.MemberStatusActive})
+	createRolloverMember(t, models.ClubMember{CN: "away", Year: "2021", Status: models.MemberStatusOnLeave})
+
+	if _, _, err := applyRollover("2025", defaultRolloverRules(), "tester"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cn := range []string{"senior", "away"} {
+		var changes []models.StatusChange
+		config.DB.Where("cn = ?", cn).Order("id asc").Find(&changes)
+		if len(changes) != 2 || changes[0].ToStatus != models.MemberStatusGraduated ||
+			changes[1].FromStatus != models.MemberStatusGraduated || changes[1].ToStatus != models.MemberStatusAlumni {
+			t.Fatalf("%s status changes: %+v", cn, changes)
+		}
+		for _, ch := range changes {
+			if _, ok := findStatusTransition(ch.FromStatus, ch.ToStatus); !ok {
+				t.Errorf("%s: %s -> %s is not an allowed transition", cn, ch.FromStatus, ch.ToStatus)
+			}
+		}
+	}
+}
+
+func TestRolloverKeepsNewYearAssignments(t *testing.T) {
+	setupRolloverTest(t)
+	createRolloverMember(t, models.ClubMember{CN: "leader", Year: "2023", Position: "社长", Status: models.MemberStatusActive})
+	createRolloverMember(t, models.ClubMember{CN: "early", Year: "2023", Position: "组长", Status: models.MemberStatusActive})
+	createRolloverMember(t, models.ClubMember{CN: "plain", Year: "2023", Position: "组长", Status: models.MemberStatusActive})
+
+	var p models.OrgPosition
+	config.DB.Where("name = ?", "社长").First(&p)
+	if p.ID == 0 {
+		p = models.OrgPosition{Name: "社长"}
+		config.DB.Create(&p)
+	}
+	// leader 在新学年开始后被任命，early 在开学前就被预先任命
+	for _, cn := range []string{"leader", "early"} {
+		config.DB.Create(&models.Tenure{CN: cn, AcademicYear: "2025", Position: "社长", PositionID: &p.ID})
+	}
+
+	if _, _, err := applyRollover("2025", defaultRolloverRules(), "tester"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"leader": "社长", "early": "社长", "plain": ""}
+	for cn, position := range want {
+		var m models.ClubMember
+		config.DB.Where("cn = ?", cn).First(&m)
+		if m.Position != position {
+			t.Errorf("%s position = %q, want %q", cn, m.Position, position)
+		}
+	}
+	// 只归档上一学年真实担任的职务
+	var archived []models.Tenure
+	config.DB.Where("academic_year = ?", "2024").Order("cn asc").Find(&archived)
+	if len(archived) != 2 || archived[0].CN != "early" || archived[0].Position != "组长" || archived[1].CN != "plain" {
+		t.Fatalf("archived tenures: %+v", archived)
+	}
+}
+
+func TestScheduledRolloverOnlyInsideWindow(t *testing.T) {
+	setupRolloverTest(t)
+	createRolloverMember(t, models.ClubMember{CN: "senior", Year: "2000", Status: models.MemberStatusActive})
+
+	now := time.Now()
+	start := academicYearStart(now)
+	// 窗口已过：不执行
+	t.Setenv("ROLLOVER_AUTO_WINDOW", now.Sub(start).Truncate(time.Second).String())
+	runScheduledRollover()
+	var count int64
+	config.DB.Model(&models.Rollover{}).Count(&count)
+	if count != 0 {
+		t.Fatal("rollover ran outside the window")
+	}
+
+	t.Setenv("ROLLOVER_AUTO_WINDOW", (now.Sub(start) + time.Hour).String())
+	runScheduledRollover()
+	config.DB.Model(&models.Rollover{}).Count(&count)
+	if count != 1 {
+		t.Fatal("rollover did not run inside the window")
+	}
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 会话有效期：
+//   JWT_ACCESS_TTL   访问 token 有效期，默认 24 小时（与引入刷新令牌前一致；
+//                    前端已在 401 时自动刷新，确认各客户端都支持刷新后可调短，如 15m）
+//   JWT_REFRESH_TTL  刷新令牌有效期，默认 30 天
+
+func accessTokenTTL() time.Duration {
+	return envDuration("JWT_ACCESS_TTL", 24*time.Hour)
+}
+
+func refreshTokenTTL() time.Duration {
+	return envDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueAccessToken 为指定会话签发短期访问 token
+func issueAccessToken(member models.ClubMember, sessionID string, mfa bool) (string, error) {
+	roles, perms, err := memberAccess(member)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		CN:          member.CN,
+		IsMember:    member.IsMember,
+		SessionID:   sessionID,
+		Roles:       roles,
+		Permissions: perms,
+		MFA:         mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return signToken(claims)
+}
+
+// startSession 创建登录会话，返回访问 token 与刷新令牌；mfa 表示本次登录通过了两步验证
+func startSession(c echo.Context, member models.ClubMember, mfa bool) (string, string, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	session := models.RefreshToken{
+		CN:        member.CN,
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		MFA:       mfa,
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := issueAccessToken(member, sessionID, mfa)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// sessionActive 判断会话是否存在且未被撤销、未过期
+func sessionActive(sessionID string) bool {
+	if sessionID == "" {
+		return false
+	}
+	var session models.RefreshToken
+	err := config.DB.Where("session_id = ? AND revoked_at IS NULL", sessionID).First(&session).Error
+	if err != nil {
+		return false
+	}
+	return time.Now().Before(session.ExpiresAt)
+}
+
+// revokeSessionsForCN 撤销指定成员的全部会话，返回撤销数量
+func revokeSessionsForCN(db *gorm.DB, cn string) (int64, error) {
+	result := db.Model(&models.RefreshToken{}).
+		Where("cn = ? AND revoked_at IS NULL", cn).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// loginResponse 登录成功后的统一响应
+func loginResponse(member models.ClubMember, accessToken, refreshToken string) echo.Map {
+	return echo.Map{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL().Seconds()),
+		"cn":            member.CN,
+		"is_member":     member.IsMember,
+		"message":       "登录成功",
+	}
+}
+
+// RefreshSession 使用刷新令牌换取新的访问 token，并轮换刷新令牌
+func RefreshSession(c echo.Context) error {
+	type RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "刷新令牌不能为空"})
+	}
+
+	var session models.RefreshToken
+	result := config.DB.Where("token_hash = ? AND revoked_at IS NULL", hashToken(req.RefreshToken)).First(&session)
+	if result.Error != nil || time.Now().After(session.ExpiresAt) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "刷新令牌无效或已过期"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", session.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "用户不存在"})
+	}
+
+	newRefreshToken, err := randomToken(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成token失败"})
+	}
+	now := time.Now()
+	// 以旧哈希为条件更新，防止同一刷新令牌被并发使用两次
+	update := config.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND token_hash = ?", session.ID, session.TokenHash).
+		Updates(map[string]interface{}{"token_hash": hashToken(newRefreshToken), "last_used_at": now})
+	if update.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "刷新会话失败"})
+	}
+	if update.RowsAffected == 0 {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "刷新令牌无效或已过期"})
+	}
+
+	accessToken, err := issueAccessToken(member, session.SessionID, session.MFA)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成token失败"})
+	}
+
+	resp := loginResponse(member, accessToken, newRefreshToken)
+	resp["message"] = "会话已刷新"
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Logout 注销当前会话
+func Logout(c echo.Context) error {
+	sessionID, _ := c.Get("session_id").(string)
+	result := config.DB.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "注销失败"})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "已退出登录"})
+}
+
+// ListSessions 管理员查看成员的有效会话
+func ListSessions(c echo.Context) error {
+	cn := c.Param("cn")
+
+	var sessions []models.RefreshToken
+	result := config.DB.Where("cn = ? AND revoked_at IS NULL AND expires_at > ?", cn, time.Now()).
+		Order("created_at desc").Find(&sessions)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+
+	items := make([]echo.Map, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, echo.Map{
+			"session_id":   s.SessionID,
+			"created_at":   s.CreatedAt,
+			"last_used_at": s.LastUsedAt,
+			"expires_at":   s.ExpiresAt,
+			"ip":           s.IP,
+			"user_agent":   s.UserAgent,
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"cn": cn, "sessions": items})
+}
+
+// RevokeSessions 管理员撤销成员的全部会话
+func RevokeSessions(c echo.Context) error {
+	cn := c.Param("cn")
+
+	count, err := revokeSessionsForCN(config.DB, cn)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "撤销会话失败"})
+	}
+	recordAudit(c, AuditSessionsRevoke, "member", cn, nil, echo.Map{"revoked": count})
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "已撤销该成员的全部会话",
+		"cn":      cn,
+		"revoked": count,
+	})
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+	"time"
+)
+
+func refresh(t *testing.T, refreshToken string) (int, string, string) {
+	t.Helper()
+	rec := serve(RefreshSession, http.MethodPost, "/api/refresh", `{"refresh_token":"`+refreshToken+`"}`, nil, nil)
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec.Code, body.Token, body.RefreshToken
+}
+
+func TestRefreshSessionRotatesRefreshToken(t *testing.T) {
+	newTestDB(t)
+	alice := newTestMember(t, "alice", true)
+	access, refreshToken := newTestSession(t, alice, false)
+	authed := VerifyToken(okHandler)
+
+	code, newAccess, newRefresh := refresh(t, refreshToken)
+	if code != http.StatusOK || newAccess == "" || newRefresh == "" || newRefresh == refreshToken {
+		t.Fatalf("refresh: %d", code)
+	}
+	// 旧刷新令牌只能使用一次
+	if code, _, _ := refresh(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: %d", code)
+	}
+	// 同一会话的新旧访问 token 都有效
+	for _, token := range []string{access, newAccess} {
+		if rec := callAuthorized(authed, token); rec.Code != http.StatusOK {
+			t.Fatalf("access token after refresh: %d %s", rec.Code, rec.Body)
+		}
+	}
+	var sessions int64
+	config.DB.Model(&models.RefreshToken{}).Where("cn = ?", "alice").Count(&sessions)
+	if sessions != 1 {
+		t.Fatalf("refresh created %d sessions", sessions)
+	}
+}
+
+func TestRefreshSessionRejectsExpiredAndUnknownTokens(t *testing.T) {
+	newTestDB(t)
+	alice := newTestMember(t, "alice", true)
+	_, refreshToken := newTestSession(t, alice, false)
+	config.DB.Model(&models.RefreshToken{}).Where("cn = ?", "alice").Update("expires_at", time.Now().Add(-time.Minute))
+
+	if code, _, _ := refresh(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expired refresh token: %d", code)
+	}
+	if code, _, _ := refresh(t, "not-a-token"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown refresh token: %d", code)
+	}
+	if code, _, _ := refresh(t, ""); code != http.StatusBadRequest {
+		t.Fatalf("empty refresh token: %d", code)
+	}
+}
+
+func TestLogoutEndsOnlyCurrentSession(t *testing.T) {
+	newTestDB(t)
+	alice := newTestMember(t, "alice", true)
+	access, refreshToken := newTestSession(t, alice, false)
+	otherAccess, _ := newTestSession(t, alice, false)
+	authed := VerifyToken(okHandler)
+
+	if rec := callAuthorized(VerifyToken(Logout), access); rec.Code != http.StatusOK {
+		t.Fatalf("logout: %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAuthorized(authed, access); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token after logout: %d", rec.Code)
+	}
+	if code, _, _ := refresh(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout: %d", code)
+	}
+	if rec := callAuthorized(authed, otherAccess); rec.Code != http.StatusOK {
+		t.Fatalf("other session after logout: %d", rec.Code)
+	}
+}
+
+func TestRevokeSessionsEndsAllSessionsOfMember(t *testing.T) {
+	newTestDB(t)
+	alice := newTestMember(t, "alice", true)
+	bob := newTestMember(t, "bob", true)
+	first, _ := newTestSession(t, alice, false)
+	second, refreshToken := newTestSession(t, alice, false)
+	bobAccess, _ := newTestSession(t, bob, false)
+
+	params := map[string]interface{}{"user_cn": "admin"}
+	list := func() int {
+		c, rec := newTestContext("alice", params)
+		ListSessions(c)
+		var body struct {
+			Sessions []map[string]interface{} `json:"sessions"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return len(body.Sessions)
+	}
+	if n := list(); n != 2 {
+		t.Fatalf("listed %d sessions", n)
+	}
+	c, rec := newTestContext("alice", params)
+	if err := RevokeSessions(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", rec.Code, rec.Body)
+	}
+	if n := list(); n != 0 {
+		t.Fatalf("%d sessions left after revoke", n)
+	}
+
+	authed := VerifyToken(okHandler)
+	for _, token := range []string{first, second} {
+		if rec := callAuthorized(authed, token); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("revoked access token: %d", rec.Code)
+		}
+	}
+	if code, _, _ := refresh(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("revoked refresh token: %d", code)
+	}
+	if rec := callAuthorized(authed, bobAccess); rec.Code != http.StatusOK {
+		t.Fatalf("other member's session revoked: %d", rec.Code)
+	}
+}
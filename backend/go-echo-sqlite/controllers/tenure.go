@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"net/http"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// validAcademicYear 学年以起始年份的四位数字表示
+func validAcademicYear(year string) bool {
+	if len(year) != 4 {
+		return false
+	}
+	_, err := strconv.Atoi(year)
+	return err == nil
+}
+
+func tenureResponse(t models.Tenure) echo.Map {
+	return echo.Map{
+		"id":            t.ID,
+		"cn":            t.CN,
+		"academic_year": t.AcademicYear,
+		"position":      t.Position,
+		"position_id":   t.PositionID,
+		"direction":     t.Direction,
+		"status":        t.Status,
+		"note":          t.Note,
+		"created_by":    t.CreatedBy,
+		"created_at":    t.CreatedAt,
+		"updated_at":    t.UpdatedAt,
+	}
+}
+
+// tenurePublicResponse 公开接口返回的任职记录，方向按成员的隐私设置隐藏
+func tenurePublicResponse(t models.Tenure, view *privacyView) echo.Map {
+	item := tenureResponse(t)
+	if !view.visible(t.CN, "direction") {
+		item["direction"] = ""
+	}
+	return item
+}
+
+// tenureExists 同一成员同一学年同一职务只保留一条记录
+func tenureExists(cn, year, position string, excludeID uint) bool {
+	var count int64
+	config.DB.Model(&models.Tenure{}).
+		Where("cn = ? AND academic_year = ? AND position = ? AND id <> ?", cn, year, position, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+// ListTenures 查询任职记录，可按 cn、year、position、status 过滤
+func ListTenures(c echo.Context) error {
+	query := config.DB.Order("academic_year asc, cn asc, id asc")
+	if cn := c.QueryParam("cn"); cn != "" {
+		query = query.Where("cn = ?", cn)
+	}
+	if year := c.QueryParam("year"); year != "" {
+		query = query.Where("academic_year = ?", year)
+	}
+	if position := c.QueryParam("position"); position != "" {
+		query = query.Where("position = ?", position)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var tenures []models.Tenure
+	if err := query.Find(&tenures).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	cns := make([]string, 0, len(tenures))
+	for _, t := range tenures {
+		cns = append(cns, t.CN)
+	}
+	view := newPrivacyView(c, cns...)
+	items := make([]echo.Map, 0, len(tenures))
+	for _, t := range tenures {
+		items = append(items, tenurePublicResponse(t, view))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetYearRoster 某一学年的成员名单，?status= 可只看在役成员等
+func GetYearRoster(c echo.Context) error {
+	year := c.Param("year")
+	if !validAcademicYear(year) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "学年格式错误，应为四位年份"})
+	}
+
+	query := config.DB.Where("academic_year = ?", year).Order("position asc, cn asc")
+	if statuses := splitQueryValues(c.QueryParam("status")); len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	var tenures []models.Tenure
+	if err := query.Find(&tenures).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	cns := make([]string, 0, len(tenures))
+	for _, t := range tenures {
+		cns = append(cns, t.CN)
+	}
+	var members []models.ClubMember
+	config.DB.Select("cn", "sex").Where("cn IN ?", cns).Find(&members)
+	sexByCN := make(map[string]string, len(members))
+	for _, m := range members {
+		sexByCN[m.CN] = m.Sex
+	}
+
+	view := newPrivacyView(c, cns...)
+	items := make([]echo.Map, 0, len(tenures))
+	for _, t := range tenures {
+		item := tenurePublicResponse(t, view)
+		item["sex"] = ""
+		if view.visible(t.CN, "sex") {
+			item["sex"] = sexByCN[t.CN]
+		}
+		items = append(items, item)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"academic_year": year,
+		"total":         len(items),
+		"members":       items,
+	})
+}
+
+// GetMemberTimeline 成员的完整任职时间线
+func GetMemberTimeline(c echo.Context) error {
+	cn := c.Param("cn")
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+
+	var tenures []models.Tenure
+	if err := config.DB.Where("cn = ?", cn).Order("academic_year asc, id asc").Find(&tenures).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	view := newPrivacyView(c, member.CN)
+	items := make([]echo.Map, 0, len(tenures))
+	for _, t := range tenures {
+		items = append(items, tenurePublicResponse(t, view))
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"cn":       cn,
+		"current":  toClubMemberPublic(member, view),
+		"timeline": items,
+	})
+}
+
+// CreateTenure 干部为成员添加任职记录
+func CreateTenure(c echo.Context) error {
+	type CreateRequest struct {
+		CN           string `json:"cn"`
+		AcademicYear string `json:"academic_year"`
+		Position     string `json:"position"`
+		Direction    string `json:"direction"`
+		Status       string `json:"status"`
+		Note         string `json:"note"`
+	}
+
+	actorCN, _ := c.Get("user_cn").(string)
+
+	var req CreateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	req.AcademicYear = strings.TrimSpace(req.AcademicYear)
+	if req.CN == "" || !validAcademicYear(req.AcademicYear) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "cn 不能为空，学年应为四位年份"})
+	}
+	if err := normalizeMemberInput(memberVocabFields{Position: &req.Position, Direction: &req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", req.CN).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+	// 未填写的字段沿用成员当前信息
+	if req.Position == "" {
+		req.Position = member.Position
+	}
+	if req.Direction == "" {
+		req.Direction = member.Direction
+	}
+	if req.Status == "" {
+		req.Status = member.Status
+	}
+	if tenureExists(req.CN, req.AcademicYear, req.Position, 0) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "该成员在该学年已有相同职务的记录"})
+	}
+
+	tenure := models.Tenure{
+		CN:           req.CN,
+		AcademicYear: req.AcademicYear,
+		Position:     req.Position,
+		Direction:    req.Direction,
+		Status:       req.Status,
+		Note:         req.Note,
+		CreatedBy:    actorCN,
+	}
+	if err := config.DB.Create(&tenure).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "添加任职记录失败"})
+	}
+	recordAudit(c, AuditTenureCreate, "tenure", strconv.Itoa(int(tenure.ID)), nil, tenureResponse(tenure))
+	return c.JSON(http.StatusCreated, tenureResponse(tenure))
+}
+
+// UpdateTenure 修改任职记录，只更新提供的字段
+func UpdateTenure(c echo.Context) error {
+	type UpdateRequest struct {
+		AcademicYear *string `json:"academic_year"`
+		Position     *string `json:"position"`
+		Direction    *string `json:"direction"`
+		Status       *string `json:"status"`
+		Note         *string `json:"note"`
+	}
+
+	id := c.Param("id")
+	var tenure models.Tenure
+	if err := config.DB.First(&tenure, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "任职记录不存在"})
+	}
+
+	var req UpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	// 组织架构任职受名额限制，学年与职位只能通过组织架构接口调整
+	if tenure.PositionID != nil && (req.AcademicYear != nil || req.Position != nil) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "该记录为组织架构任职，请通过组织架构接口调整学年或职位"})
+	}
+
+	if err := normalizeMemberInput(memberVocabFields{Position: req.Position, Direction: req.Direction}); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	before := tenureResponse(tenure)
+	if req.AcademicYear != nil {
+		if !validAcademicYear(*req.AcademicYear) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "学年格式错误，应为四位年份"})
+		}
+		tenure.AcademicYear = *req.AcademicYear
+	}
+	if req.Position != nil {
+		tenure.Position = *req.Position
+	}
+	if req.Direction != nil {
+		tenure.Direction = *req.Direction
+	}
+	if req.Status != nil {
+		tenure.Status = *req.Status
+	}
+	if req.Note != nil {
+		tenure.Note = *req.Note
+	}
+	if tenureExists(tenure.CN, tenure.AcademicYear, tenure.Position, tenure.ID) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "该成员在该学年已有相同职务的记录"})
+	}
+
+	if err := config.DB.Save(&tenure).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "更新失败"})
+	}
+	recordAudit(c, AuditTenureUpdate, "tenure", id, before, tenureResponse(tenure))
+	return c.JSON(http.StatusOK, tenureResponse(tenure))
+}
+
+// DeleteTenure 删除任职记录
+func DeleteTenure(c echo.Context) error {
+	id := c.Param("id")
+	var tenure models.Tenure
+	if err := config.DB.First(&tenure, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "任职记录不存在"})
+	}
+	if err := config.DB.Delete(&tenure).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
+	}
+	recordAudit(c, AuditTenureDelete, "tenure", id, tenureResponse(tenure), nil)
+	return c.NoContent(http.StatusNoContent)
+}
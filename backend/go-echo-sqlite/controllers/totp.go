@@ -0,0 +1,488 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// 两步验证配置：
+//   TOTP_ISSUER               otpauth URI 中显示的签发方，默认 SeventhCentury
+//   TOTP_REQUIRED_FOR_ADMINS  为 true 时管理员必须启用两步验证才能登录，未通过两步验证的会话与令牌不带管理员权限
+
+const (
+	totpPeriod        = 30 // RFC 6238 默认时间步长（秒）
+	totpDigits        = 6
+	totpSkew          = 1 // 允许前后各一个时间步的时钟误差
+	recoveryCodeCount = 10
+
+	mfaPurposeLogin  = "mfa_login"
+	mfaPurposeEnroll = "mfa_enroll"
+	mfaChallengeTTL  = 5 * time.Minute
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func totpIssuer() string {
+	if issuer := strings.TrimSpace(os.Getenv("TOTP_ISSUER")); issuer != "" {
+		return issuer
+	}
+	return "SeventhCentury"
+}
+
+func totpRequiredForAdmins() bool { return envBool("TOTP_REQUIRED_FOR_ADMINS") }
+
+// applyAdminMFAMandate 开启 TOTP_REQUIRED_FOR_ADMINS 时，未通过两步验证的管理员会话或个人令牌
+// 只保留其余角色的权限，并标记 mfa_required 供 RequirePermission 给出提示
+func applyAdminMFAMandate(c echo.Context) {
+	if !totpRequiredForAdmins() {
+		return
+	}
+	roles, _ := c.Get("roles").([]string)
+	if mfa, _ := c.Get("mfa").(bool); mfa || !containsString(roles, RoleAdmin) {
+		return
+	}
+
+	var rest []string
+	for _, r := range roles {
+		if r != RoleAdmin {
+			rest = append(rest, r)
+		}
+	}
+	allowed, _ := rolePermissions(rest)
+	current, _ := c.Get("permissions").([]string)
+	perms := make([]string, 0, len(current))
+	for _, p := range current {
+		if containsString(allowed, p) {
+			perms = append(perms, p)
+		}
+	}
+	c.Set("permissions", perms)
+	c.Set("mfa_required", true)
+}
+
+// RevokeAdminSessionsWithoutMFA 开启 TOTP_REQUIRED_FOR_ADMINS 时撤销管理员未通过两步验证的会话与个人令牌，
+// 启动时调用；返回撤销的会话数与令牌数
+func RevokeAdminSessionsWithoutMFA() (int64, int64, error) {
+	if !totpRequiredForAdmins() {
+		return 0, 0, nil
+	}
+	admins := config.DB.Model(&models.MemberRole{}).Select("cn").Where("role_name = ?", RoleAdmin)
+	now := time.Now()
+
+	sessions := config.DB.Model(&models.RefreshToken{}).
+		Where("cn IN (?) AND mfa = ? AND revoked_at IS NULL", admins, false).
+		Update("revoked_at", now)
+	if sessions.Error != nil {
+		return 0, 0, sessions.Error
+	}
+	tokens := config.DB.Model(&models.AccessToken{}).
+		Where("owner_type = ? AND owner IN (?) AND mfa = ? AND revoked_at IS NULL", tokenOwnerMember, admins, false).
+		Update("revoked_at", now)
+	if tokens.Error != nil {
+		return sessions.RowsAffected, 0, tokens.Error
+	}
+	return sessions.RowsAffected, tokens.RowsAffected, nil
+}
+
+// generateTOTPSecret 生成 160 位随机密钥（Base32）
+func generateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpCode 按 RFC 4226/6238 计算指定时间步的验证码
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// verifyTOTP 校验验证码，返回匹配的时间步；lastStep 及之前的时间步视为已使用
+func verifyTOTP(secret, code string, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	now := time.Now().Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := now + int64(i)
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpURI(cn, secret string) string {
+	issuer := totpIssuer()
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + cn)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文与哈希列表（JSON）
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		raw := hex.EncodeToString(b)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(code))
+	}
+	stored, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(stored), nil
+}
+
+// consumeRecoveryCode 校验并移除一个恢复码，返回剩余哈希列表
+func consumeRecoveryCode(stored, code string) (string, bool) {
+	var hashes []string
+	if err := json.Unmarshal([]byte(stored), &hashes); err != nil {
+		return stored, false
+	}
+	target := hashToken(strings.ToLower(strings.TrimSpace(code)))
+	for i, h := range hashes {
+		if hmac.Equal([]byte(h), []byte(target)) {
+			rest := append(hashes[:i:i], hashes[i+1:]...)
+			out, _ := json.Marshal(rest)
+			return string(out), true
+		}
+	}
+	return stored, false
+}
+
+func remainingRecoveryCodes(stored string) int {
+	var hashes []string
+	_ = json.Unmarshal([]byte(stored), &hashes)
+	return len(hashes)
+}
+
+// mfaChallengeClaims 两步验证的临时凭证，不含 sid，不能当作访问 token 使用
+type mfaChallengeClaims struct {
+	CN      string `json:"cn"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+func issueMFAChallenge(cn, purpose string) (string, error) {
+	now := time.Now()
+	return signToken(&mfaChallengeClaims{
+		CN:      cn,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+}
+
+func parseMFAChallenge(tokenString, purpose string) (string, error) {
+	claims := &mfaChallengeClaims{}
+	token, err := parseToken(tokenString, claims)
+	if err != nil || !token.Valid || claims.Purpose != purpose || claims.CN == "" {
+		return "", errors.New("验证凭证无效或已过期")
+	}
+	return claims.CN, nil
+}
+
+// totpActor 两步验证管理接口的调用者：已登录会话，或管理员强制启用时登录返回的 enrollment_token
+func totpActor(c echo.Context, enrollmentToken string) (string, bool) {
+	if enrollmentToken != "" {
+		cn, err := parseMFAChallenge(enrollmentToken, mfaPurposeEnroll)
+		return cn, err == nil
+	}
+
+	tokenString := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return "", false
+	}
+	claims := &Claims{}
+	token, err := parseToken(tokenString, claims)
+	if err != nil || !token.Valid || !sessionActive(claims.SessionID) {
+		return "", false
+	}
+	return claims.CN, true
+}
+
+// EnrollTOTP 生成待确认的 TOTP 密钥
+func EnrollTOTP(c echo.Context) error {
+	type EnrollRequest struct {
+		EnrollmentToken string `json:"enrollment_token"`
+	}
+
+	var req EnrollRequest
+	_ = c.Bind(&req)
+
+	cn, ok := totpActor(c, req.EnrollmentToken)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "未提供认证token"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "用户不存在"})
+	}
+	if member.TOTPEnabled {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "两步验证已启用"})
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成密钥失败"})
+	}
+	if err := config.DB.Model(&member).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "保存密钥失败"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"secret":      secret,
+		"otpauth_uri": totpURI(member.CN, secret),
+		"message":     "请使用验证器应用扫描后提交验证码以完成启用",
+	})
+}
+
+// ConfirmTOTP 提交验证码确认启用，返回一次性恢复码
+func ConfirmTOTP(c echo.Context) error {
+	type ConfirmRequest struct {
+		Code            string `json:"code"`
+		EnrollmentToken string `json:"enrollment_token"`
+	}
+
+	var req ConfirmRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	cn, ok := totpActor(c, req.EnrollmentToken)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "未提供认证token"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "用户不存在"})
+	}
+	if member.TOTPEnabled {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "两步验证已启用"})
+	}
+	if member.TOTPSecret == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请先生成两步验证密钥"})
+	}
+
+	step, ok := verifyTOTP(member.TOTPSecret, req.Code, member.TOTPLastStep)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "验证码错误"})
+	}
+
+	codes, stored, err := generateRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成恢复码失败"})
+	}
+	if err := config.DB.Model(&member).Updates(map[string]interface{}{
+		"totp_enabled":        true,
+		"totp_last_step":      step,
+		"totp_recovery_codes": stored,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "启用两步验证失败"})
+	}
+	c.Set("user_cn", member.CN)
+	recordAudit(c, AuditTOTPEnable, "member", member.CN, nil, nil)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":        "两步验证已启用，请妥善保存恢复码，恢复码只显示一次",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP 本人关闭两步验证（需提供验证码或恢复码）
+func DisableTOTP(c echo.Context) error {
+	type DisableRequest struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	cn, _ := c.Get("user_cn").(string)
+
+	var req DisableRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	if totpRequiredForAdmins() && isMCPAdminCN(cn) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "管理员账号必须启用两步验证"})
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "用户不存在"})
+	}
+	if !member.TOTPEnabled {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "尚未启用两步验证"})
+	}
+
+	if _, ok := checkSecondFactor(&member, req.Code, req.RecoveryCode); !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "验证码错误"})
+	}
+
+	if err := clearTOTP(member.CN); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "关闭两步验证失败"})
+	}
+	recordAudit(c, AuditTOTPDisable, "member", member.CN, nil, nil)
+	return c.JSON(http.StatusOK, echo.Map{"message": "两步验证已关闭"})
+}
+
+// ResetMemberTOTP 管理员为丢失设备的成员重置两步验证
+func ResetMemberTOTP(c echo.Context) error {
+	cn := c.Param("cn")
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "成员不存在"})
+	}
+	if err := clearTOTP(member.CN); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "重置两步验证失败"})
+	}
+	if _, err := revokeSessionsForCN(config.DB, member.CN); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "撤销会话失败"})
+	}
+	recordAudit(c, AuditTOTPDisable, "member", member.CN, echo.Map{"totp_enabled": member.TOTPEnabled}, echo.Map{"totp_enabled": false})
+	return c.NoContent(http.StatusNoContent)
+}
+
+func clearTOTP(cn string) error {
+	return config.DB.Model(&models.ClubMember{}).Where("cn = ?", cn).Updates(map[string]interface{}{
+		"totp_enabled":        false,
+		"totp_secret":         "",
+		"totp_last_step":      0,
+		"totp_recovery_codes": "",
+	}).Error
+}
+
+// checkSecondFactor 校验验证码或恢复码，并持久化时间步/剩余恢复码；返回是否使用了恢复码
+func checkSecondFactor(member *models.ClubMember, code, recoveryCode string) (bool, bool) {
+	if code != "" {
+		step, ok := verifyTOTP(member.TOTPSecret, code, member.TOTPLastStep)
+		if !ok {
+			return false, false
+		}
+		// 以旧时间步为条件更新，避免同一验证码被并发使用
+		result := config.DB.Model(&models.ClubMember{}).
+			Where("cn = ? AND totp_last_step = ?", member.CN, member.TOTPLastStep).
+			Update("totp_last_step", step)
+		return false, result.Error == nil && result.RowsAffected == 1
+	}
+	if recoveryCode != "" {
+		rest, ok := consumeRecoveryCode(member.TOTPRecoveryCodes, recoveryCode)
+		if !ok {
+			return true, false
+		}
+		result := config.DB.Model(&models.ClubMember{}).
+			Where("cn = ? AND totp_recovery_codes = ?", member.CN, member.TOTPRecoveryCodes).
+			Update("totp_recovery_codes", rest)
+		member.TOTPRecoveryCodes = rest
+		return true, result.Error == nil && result.RowsAffected == 1
+	}
+	return false, false
+}
+
+// LoginTOTP 登录第二步：提交 challenge 与验证码（或恢复码）后签发会话
+func LoginTOTP(c echo.Context) error {
+	type LoginTOTPRequest struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	var req LoginTOTPRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	cn, err := parseMFAChallenge(req.Challenge, mfaPurposeLogin)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	}
+
+	if blocked, err := authThrottled(c, cn); blocked {
+		return err
+	}
+
+	var member models.ClubMember
+	if err := config.DB.Where("cn = ?", cn).First(&member).Error; err != nil || !member.TOTPEnabled {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "验证凭证无效或已过期"})
+	}
+
+	usedRecovery, ok := checkSecondFactor(&member, req.Code, req.RecoveryCode)
+	if !ok {
+		recordAuthFailure(c, authActionLoginTOTP, cn)
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "验证码错误"})
+	}
+	recordAuthSuccess(c, authActionLoginTOTP, cn)
+
+	accessToken, refreshToken, err := startSession(c, member, true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成token失败"})
+	}
+
+	resp := loginResponse(member, accessToken, refreshToken)
+	if usedRecovery {
+		resp["recovery_codes_remaining"] = remainingRecoveryCodes(member.TOTPRecoveryCodes)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
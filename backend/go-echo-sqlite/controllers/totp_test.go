@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA-1 测试向量，密钥为 ASCII "12345678901234567890"。
+// 附录给出 8 位验证码，6 位验证码即其后 6 位。
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+	for _, v := range vectors {
+		got, err := totpCode(secret, v.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("T=%d: %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("T=%d: got %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowercaseSecret(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	upper, _ := totpCode(secret, 1)
+	lower, err := totpCode(strings.ToLower(secret), 1)
+	if err != nil || lower != upper {
+		t.Fatalf("lowercase secret: got %q, %v; want %q", lower, err, upper)
+	}
+}
+
+func TestVerifyTOTPRejectsReplayAndSkew(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix() / totpPeriod
+	code, _ := totpCode(secret, now)
+
+	step, ok := verifyTOTP(secret, code, 0)
+	if !ok || step != now {
+		t.Fatalf("current code rejected: step=%d ok=%v", step, ok)
+	}
+	// 同一时间步已使用过，不能重放
+	if _, ok := verifyTOTP(secret, code, now); ok {
+		t.Error("replayed code accepted")
+	}
+	// 超出允许误差的时间步
+	old, _ := totpCode(secret, now-totpSkew-2)
+	if old != code {
+		if _, ok := verifyTOTP(secret, old, 0); ok {
+			t.Error("code outside skew window accepted")
+		}
+	}
+	if _, ok := verifyTOTP(secret, "12345", 0); ok {
+		t.Error("short code accepted")
+	}
+}
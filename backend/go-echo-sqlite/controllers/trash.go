@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 回收站配置：
+//   TRASH_RETENTION         软删除记录保留时长，超过后自动彻底删除，默认 720h（30 天）
+//   TRASH_PURGE_INTERVAL    自动清理的执行间隔，默认 1h
+//   AVATAR_QUARANTINE_DIR   已删除主页头像的隔离目录（不对外提供访问），默认 pics_quarantine
+
+// 回收站支持的实体
+const (
+	trashMembers    = "members"
+	trashProfiles   = "profiles"
+	trashActivities = "activities"
+)
+
+var trashEntities = []string{trashMembers, trashProfiles, trashActivities}
+
+var errTrashConflict = errors.New("已存在同名的有效记录，无法恢复")
+
+func trashRetention() time.Duration { return envDuration("TRASH_RETENTION", 30*24*time.Hour) }
+
+func avatarQuarantineDir() string {
+	if dir := strings.TrimSpace(os.Getenv("AVATAR_QUARANTINE_DIR")); dir != "" {
+		return dir
+	}
+	return "pics_quarantine"
+}
+
+func quarantinePath(avatar string) string {
+	return filepath.Join(avatarQuarantineDir(), filepath.Base(avatar))
+}
+
+// quarantineAvatar 将头像移入隔离目录，主页恢复时再移回原位置
+func quarantineAvatar(avatar string) error {
+	if avatar == "" {
+		return nil
+	}
+	if err := os.MkdirAll(avatarQuarantineDir(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(avatar, quarantinePath(avatar)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func restoreAvatar(avatar string) error {
+	if avatar == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(avatar), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(quarantinePath(avatar), avatar); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func purgeQuarantinedAvatar(avatar string) {
+	if avatar == "" {
+		return
+	}
+	if err := os.Remove(quarantinePath(avatar)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("删除隔离头像失败: %v\n", err)
+	}
+}
+
+// trashModel 返回实体对应的模型指针
+func trashModel(entity string) (interface{}, bool) {
+	switch entity {
+	case trashMembers:
+		return &models.ClubMember{}, true
+	case trashProfiles:
+		return &models.MemberProfile{}, true
+	case trashActivities:
+		return &models.Activity{}, true
+	}
+	return nil, false
+}
+
+// trashSummary 回收站列表中展示的字段
+func trashSummary(record interface{}) echo.Map {
+	var item echo.Map
+	var deletedAt gorm.DeletedAt
+	switch r := record.(type) {
+	case *models.ClubMember:
+		item = echo.Map{"id": r.ID, "cn": r.CN, "year": r.Year, "direction": r.Direction, "position": r.Position}
+		deletedAt = r.DeletedAt
+	case *models.MemberProfile:
+		item = echo.Map{"id": r.ID, "cn": r.CN, "avatar": r.Avatar, "signature": r.Signature}
+		deletedAt = r.DeletedAt
+	case *models.Activity:
+		item = echo.Map{"id": r.ID, "name": r.Name, "time": r.Time}
+		deletedAt = r.DeletedAt
+	}
+	if deletedAt.Valid {
+		item["deleted_at"] = deletedAt.Time
+		item["purge_at"] = deletedAt.Time.Add(trashRetention())
+	}
+	return item
+}
+
+// findTrashed 列出软删除的记录；before 非零时只返回在该时间之前删除的记录
+func findTrashed(entity string, before time.Time) ([]interface{}, error) {
+	query := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc")
+	if !before.IsZero() {
+		query = query.Where("deleted_at < ?", before)
+	}
+
+	var out []interface{}
+	switch entity {
+	case trashMembers:
+		var rows []models.ClubMember
+		if err := query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			out = append(out, &rows[i])
+		}
+	case trashProfiles:
+		var rows []models.MemberProfile
+		if err := query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			out = append(out, &rows[i])
+		}
+	case trashActivities:
+		var rows []models.Activity
+		if err := query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			out = append(out, &rows[i])
+		}
+	}
+	return out, nil
+}
+
+// restoreTrashed 恢复一条软删除记录
+func restoreTrashed(record interface{}) error {
+	var count int64
+	switch r := record.(type) {
+	case *models.ClubMember:
+		config.DB.Model(&models.ClubMember{}).Where("cn = ?", r.CN).Count(&count)
+	case *models.MemberProfile:
+		config.DB.Model(&models.MemberProfile{}).Where("cn = ?", r.CN).Count(&count)
+	}
+	if count > 0 {
+		return errTrashConflict
+	}
+
+	if m, ok := record.(*models.ClubMember); ok {
+		return restoreMemberWithRelations(m)
+	}
+	if p, ok := record.(*models.MemberProfile); ok {
+		if err := restoreAvatar(p.Avatar); err != nil {
+			return err
+		}
+	}
+	return config.DB.Unscoped().Model(record).Update("deleted_at", nil).Error
+}
+
+// restoreMemberWithRelations 在同一事务中恢复成员及随其删除的个人主页、任职记录、角色与外部身份绑定，
+// 成员删除之前单独删除的保留在回收站；头像在事务提交后移回
+func restoreMemberWithRelations(m *models.ClubMember) error {
+	// Update 会把 m 的 DeletedAt 清空，先记下删除时间用于查找随其删除的记录
+	deletedAt := m.DeletedAt
+	var profile *models.MemberProfile
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(m).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if !deletedAt.Valid {
+			return nil
+		}
+		for _, model := range []interface{}{&models.Tenure{}, &models.MemberRole{}, &models.ExternalIdentity{}} {
+			if err := tx.Unscoped().Model(model).
+				Where("cn = ? AND deleted_at >= ?", m.CN, deletedAt.Time).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		var p models.MemberProfile
+		err := tx.Unscoped().Where("cn = ? AND deleted_at >= ?", m.CN, deletedAt.Time).First(&p).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 成员已有新的主页时，旧主页留在回收站
+		var live int64
+		if err := tx.Model(&models.MemberProfile{}).Where("cn = ?", m.CN).Count(&live).Error; err != nil {
+			return err
+		}
+		if live > 0 {
+			return nil
+		}
+		if err := tx.Unscoped().Model(&p).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		profile = &p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if profile != nil {
+		if err := restoreAvatar(profile.Avatar); err != nil {
+			fmt.Printf("恢复头像文件失败: %v\n", err)
+		}
+	}
+	syncMembersAsync()
+	return nil
+}
+
+// purgeTrashed 彻底删除一条软删除记录及其隔离的头像
+func purgeTrashed(record interface{}) error {
+	if m, ok := record.(*models.ClubMember); ok {
+		// 成员彻底删除时，其回收站中的个人主页、角色与外部身份一并清除
+		if err := purgeTrashedProfiles(m.CN); err != nil {
+			return err
+		}
+		return config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Delete(record).Error; err != nil {
+				return err
+			}
+			return purgeMemberRelations(tx, m.CN)
+		})
+	}
+
+	if err := config.DB.Unscoped().Delete(record).Error; err != nil {
+		return err
+	}
+	if p, ok := record.(*models.MemberProfile); ok {
+		purgeQuarantinedAvatar(p.Avatar)
+	}
+	return nil
+}
+
+// purgeTrashedProfiles 新建主页前清除回收站中同名的旧主页（唯一索引包含软删除记录）
+func purgeTrashedProfiles(cn string) error {
+	var rows []models.MemberProfile
+	if err := config.DB.Unscoped().Where("cn = ? AND deleted_at IS NOT NULL", cn).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		if err := purgeTrashed(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeExpiredTrash 彻底删除超过保留期的软删除记录
+func PurgeExpiredTrash() {
+	cutoff := time.Now().Add(-trashRetention())
+	for _, entity := range trashEntities {
+		records, err := findTrashed(entity, cutoff)
+		if err != nil {
+			fmt.Printf("查询过期回收站记录失败(%s): %v\n", entity, err)
+			continue
+		}
+		for _, record := range records {
+			if err := purgeTrashed(record); err != nil {
+				fmt.Printf("清理回收站记录失败(%s): %v\n", entity, err)
+			}
+		}
+	}
+}
+
+// StartTrashPurge 按 TRASH_PURGE_INTERVAL 定时清理回收站
+func StartTrashPurge() {
+	interval := envDuration("TRASH_PURGE_INTERVAL", time.Hour)
+	go func() {
+		PurgeExpiredTrash()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			PurgeExpiredTrash()
+		}
+	}()
+}
+
+// findTrashedRecord 按 :entity/:id 查找回收站中的记录，失败时已写入响应
+func findTrashedRecord(c echo.Context) (interface{}, bool, error) {
+	record, ok := trashModel(c.Param("entity"))
+	if !ok {
+		return nil, false, c.JSON(http.StatusBadRequest, echo.Map{"error": "不支持的类型"})
+	}
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(record, c.Param("id")).Error; err != nil {
+		return nil, false, c.JSON(http.StatusNotFound, echo.Map{"error": "回收站中没有该记录"})
+	}
+	return record, true, nil
+}
+
+// ListTrash 列出某类实体的软删除记录
+func ListTrash(c echo.Context) error {
+	entity := c.Param("entity")
+	if _, ok := trashModel(entity); !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "不支持的类型"})
+	}
+
+	records, err := findTrashed(entity, time.Time{})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	items := make([]echo.Map, 0, len(records))
+	for _, r := range records {
+		items = append(items, trashSummary(r))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// RestoreTrash 从回收站恢复记录
+func RestoreTrash(c echo.Context) error {
+	record, ok, err := findTrashedRecord(c)
+	if !ok {
+		return err
+	}
+
+	if err := restoreTrashed(record); err != nil {
+		if errors.Is(err, errTrashConflict) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "恢复失败"})
+	}
+	summary := trashSummary(record)
+	delete(summary, "purge_at")
+	recordAudit(c, AuditTrashRestore, c.Param("entity"), c.Param("id"), nil, summary)
+	return c.JSON(http.StatusOK, echo.Map{"message": "已恢复", "item": summary})
+}
+
+// PurgeTrash 立即彻底删除回收站中的记录
+func PurgeTrash(c echo.Context) error {
+	record, ok, err := findTrashedRecord(c)
+	if !ok {
+		return err
+	}
+
+	summary := trashSummary(record)
+	if err := purgeTrashed(record); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
+	}
+	delete(summary, "purge_at")
+	recordAudit(c, AuditTrashPurge, c.Param("entity"), c.Param("id"), summary, nil)
+	return c.NoContent(http.StatusNoContent)
+}
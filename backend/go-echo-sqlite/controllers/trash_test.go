@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func setupTrashTest(t *testing.T) models.ClubMember {
+	t.Helper()
+	newTestDB(t)
+	member := models.ClubMember{CN: "alice", Password: "x", IsMember: true}
+	if err := config.DB.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.MemberProfile{CN: "alice", Signature: "hello"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return member
+}
+
+func restoreTrashedMember(t *testing.T, cn string) {
+	t.Helper()
+	var trashed models.ClubMember
+	if err := config.DB.Unscoped().Where("cn = ? AND deleted_at IS NOT NULL", cn).First(&trashed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreTrashed(&trashed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreMemberRestoresProfileDeletedWithIt(t *testing.T) {
+	member := setupTrashTest(t)
+	if _, err := deleteMemberCascade(member); err != nil {
+		t.Fatal(err)
+	}
+	restoreTrashedMember(t, "alice")
+
+	var count int64
+	config.DB.Model(&models.MemberProfile{}).Where("cn = ?", "alice").Count(&count)
+	if count != 1 {
+		t.Fatal("profile deleted with the member was not restored")
+	}
+}
+
+func TestRestoreMemberKeepsEarlierDeletedProfileInTrash(t *testing.T) {
+	member := setupTrashTest(t)
+	// 主页先被单独删除，之后成员才被删除
+	if err := config.DB.Where("cn = ?", "alice").Delete(&models.MemberProfile{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := deleteMemberCascade(member); err != nil {
+		t.Fatal(err)
+	}
+	restoreTrashedMember(t, "alice")
+
+	var count int64
+	config.DB.Model(&models.ClubMember{}).Where("cn = ?", "alice").Count(&count)
+	if count != 1 {
+		t.Fatal("member not restored")
+	}
+	config.DB.Model(&models.MemberProfile{}).Where("cn = ?", "alice").Count(&count)
+	if count != 0 {
+		t.Fatal("profile deleted before the member was restored with it")
+	}
+}
+
+func TestDeletedMemberTenuresFollowTheMember(t *testing.T) {
+	member := setupTrashTest(t)
+	config.DB.Create(&models.Tenure{CN: "alice", AcademicYear: "2023", Position: "组长"})
+	// 成员删除前单独删除的任职记录不随成员恢复
+	early := models.Tenure{CN: "alice", AcademicYear: "2022", Position: "组员"}
+	config.DB.Create(&early)
+	config.DB.Delete(&early)
+	time.Sleep(10 * time.Millisecond)
+
+	del, err := deleteMemberCascade(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	config.DB.Model(&models.Tenure{}).Where("cn = ?", "alice").Count(&count)
+	if del.TenuresDeleted != 1 || count != 0 {
+		t.Fatalf("tenures still visible after delete: deleted=%d visible=%d", del.TenuresDeleted, count)
+	}
+
+	restoreTrashedMember(t, "alice")
+	var years []string
+	config.DB.Model(&models.Tenure{}).Where("cn = ?", "alice").Pluck("academic_year", &years)
+	if len(years) != 1 || years[0] != "2023" {
+		t.Fatalf("restored tenures: %v", years)
+	}
+}
+
+func TestTrashRelationsOfDeletedMembers(t *testing.T) {
+	setupTrashTest(t)
+	// 早期版本删除成员时任职记录仍然有效
+	config.DB.Create(&models.Tenure{CN: "alice", AcademicYear: "2023", Position: "组长"})
+	config.DB.Create(&models.MemberRole{CN: "alice", RoleName: RoleAdmin})
+	config.DB.Where("cn = ?", "alice").Delete(&models.ClubMember{})
+	config.DB.Create(&models.ClubMember{CN: "bob", Password: "x", IsMember: true})
+	config.DB.Create(&models.Tenure{CN: "bob", AcademicYear: "2023", Position: "组员"})
+
+	n, err := TrashRelationsOfDeletedMembers()
+	if err != nil || n != 2 {
+		t.Fatalf("trashed %d tenures: %v", n, err)
+	}
+	var cns []string
+	config.DB.Model(&models.Tenure{}).Pluck("cn", &cns)
+	if len(cns) != 1 || cns[0] != "bob" {
+		t.Fatalf("visible tenures: %v", cns)
+	}
+
+	restoreTrashedMember(t, "alice")
+	var count int64
+	config.DB.Model(&models.Tenure{}).Where("cn = ?", "alice").Count(&count)
+	if count != 1 {
+		t.Fatal("tenure not restored with the member")
+	}
+}
+
+func TestTrashedMemberRolesAndIdentitiesFollowTheMember(t *testing.T) {
+	member := setupTrashTest(t)
+	for _, record := range []interface{}{
+		&models.MemberRole{CN: "alice", RoleName: RoleAdmin},
+		&models.ExternalIdentity{Issuer: "https://idp.example", Subject: "alice-sub", CN: "alice"},
+		&models.ClubMember{CN: "carol", Password: "x", IsMember: true},
+		&models.MemberRole{CN: "carol", RoleName: RoleAdmin},
+	} {
+		if err := config.DB.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := deleteMemberCascade(member); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回收站中的管理员不再计入人数，carol 是最后一位管理员
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), rec)
+	c.SetParamNames("cn", "role")
+	c.SetParamValues("carol", RoleAdmin)
+	c.Set("user_cn", "dave")
+	if err := RemoveMemberRole(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("removed the last live admin: %d %s", rec.Code, rec.Body)
+	}
+	var count int64
+	config.DB.Model(&models.ExternalIdentity{}).Where("cn = ?", "alice").Count(&count)
+	if count != 0 {
+		t.Fatal("identity of a trashed member still active")
+	}
+
+	restoreTrashedMember(t, "alice")
+	roles, err := memberRoles(models.ClubMember{CN: "alice", IsMember: true})
+	if err != nil || len(roles) != 1 || roles[0] != RoleAdmin {
+		t.Fatalf("restored roles: %v %v", roles, err)
+	}
+	config.DB.Model(&models.ExternalIdentity{}).Where("cn = ?", "alice").Count(&count)
+	if count != 1 {
+		t.Fatal("identity not restored with the member")
+	}
+}
+
+func TestPurgedMemberFreesExternalIdentity(t *testing.T) {
+	member := setupTrashTest(t)
+	identity := models.ExternalIdentity{Issuer: "https://idp.example", Subject: "alice-sub", CN: "alice"}
+	if err := config.DB.Create(&identity).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deleteMemberCascade(member); err != nil {
+		t.Fatal(err)
+	}
+	var trashed models.ClubMember
+	config.DB.Unscoped().Where("cn = ?", "alice").First(&trashed)
+	if err := purgeTrashed(&trashed); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一外部账号可以重新绑定到其他成员
+	relinked := models.ExternalIdentity{Issuer: "https://idp.example", Subject: "alice-sub", CN: "bob"}
+	if err := config.DB.Create(&relinked).Error; err != nil {
+		t.Fatalf("relink after purge: %v", err)
+	}
+}
+
+func TestRestoreMemberIsAtomic(t *testing.T) {
+	member := setupTrashTest(t)
+	config.DB.Create(&models.Tenure{CN: "alice", AcademicYear: "2023", Position: "组长"})
+	if _, err := deleteMemberCascade(member); err != nil {
+		t.Fatal(err)
+	}
+	// 恢复任职记录时出错：成员和主页都不能单独恢复
+	config.DB.Callback().Update().Before("gorm:update").Register("fail_tenures", func(db *gorm.DB) {
+		if db.Statement.Table == "tenures" {
+			db.AddError(errors.New("boom"))
+		}
+	})
+	var trashed models.ClubMember
+	config.DB.Unscoped().Where("cn = ?", "alice").First(&trashed)
+	if err := restoreTrashed(&trashed); err == nil {
+		t.Fatal("restore succeeded despite the failure")
+	}
+	var count int64
+	config.DB.Model(&models.ClubMember{}).Where("cn = ?", "alice").Count(&count)
+	if count != 0 {
+		t.Fatal("member restored without its tenures")
+	}
+
+	config.DB.Callback().Update().Remove("fail_tenures")
+	restoreTrashedMember(t, "alice")
+	config.DB.Model(&models.Tenure{}).Where("cn = ?", "alice").Count(&count)
+	if count != 1 {
+		t.Fatal("tenure not restored on retry")
+	}
+}
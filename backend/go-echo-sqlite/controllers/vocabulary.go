@@ -0,0 +1,520 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 受控词表字段，与 club_members 的列名一致
+const (
+	vocabSex       = "sex"
+	vocabPosition  = "position"
+	vocabDirection = "direction"
+)
+
+var vocabFields = []string{vocabSex, vocabPosition, vocabDirection}
+
+var vocabFieldLabels = map[string]string{
+	vocabSex:       "性别",
+	vocabPosition:  "职务",
+	vocabDirection: "方向",
+	"year":         "入学年份",
+}
+
+var errVocabConflict = errors.New("该写法已被同一词表中的其他标准值使用")
+
+// defaultVocabularies 默认词表，与注册页面的选项及社团实际使用的职务一致
+var defaultVocabularies = map[string][]struct {
+	Value   string
+	Aliases []string
+}{
+	vocabSex: {
+		{"男", []string{"male", "m", "男生"}},
+		{"女", []string{"female", "f", "女生"}},
+		{"其他", []string{"other", "保密"}},
+	},
+	vocabDirection: {
+		{"动画", []string{"MAD", "动画组", "动画方向"}},
+		{"静止系", []string{"静止", "静态", "静止系组"}},
+		{"三维", []string{"3D", "MMD", "三维组", "3D组"}},
+	},
+	vocabPosition: {
+		{"组员", []string{"成员", "社员", "普通成员"}},
+		{"组长", []string{"队长", "leader"}},
+		{"副组长", []string{"副队长"}},
+	},
+}
+
+// SeedVocabularies 补充缺少的默认词条；标准值已作为词条或别名存在（包括已删除的词条）时不再写入，
+// 管理员删除或改名的默认词条不会被恢复
+func SeedVocabularies() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, field := range vocabFields {
+			var terms []models.VocabTerm
+			if err := tx.Unscoped().Where("field = ?", field).Find(&terms).Error; err != nil {
+				return err
+			}
+			existing := make(map[string]bool)
+			for _, t := range terms {
+				for _, s := range append([]string{t.Value}, termAliases(t)...) {
+					existing[vocabKey(s)] = true
+				}
+			}
+			for i, d := range defaultVocabularies[field] {
+				if existing[vocabKey(d.Value)] {
+					continue
+				}
+				aliases, _ := json.Marshal(d.Aliases)
+				if err := tx.Create(&models.VocabTerm{Field: field, Value: d.Value, Aliases: string(aliases), SortOrder: i}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// vocabKey 比较写法时忽略大小写与空白
+func vocabKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+func termAliases(t models.VocabTerm) []string {
+	aliases := []string{}
+	if t.Aliases != "" {
+		_ = json.Unmarshal([]byte(t.Aliases), &aliases)
+	}
+	return aliases
+}
+
+// vocabulary 载入内存的词表，一次请求或一次批量操作内复用
+type vocabulary struct {
+	lookup map[string]map[string]string // 字段 -> 写法 -> 标准值
+	values map[string][]string          // 字段 -> 标准值，按排序
+}
+
+func (v *vocabulary) add(field, value string, spellings ...string) {
+	if v.lookup[field] == nil {
+		v.lookup[field] = make(map[string]string)
+	}
+	if _, ok := v.lookup[field][vocabKey(value)]; !ok {
+		v.values[field] = append(v.values[field], value)
+	}
+	for _, s := range append([]string{value}, spellings...) {
+		if _, ok := v.lookup[field][vocabKey(s)]; !ok {
+			v.lookup[field][vocabKey(s)] = value
+		}
+	}
+}
+
+// loadVocabulary 读取词表；excludeID 非零时跳过该词条（修改词条时检查冲突用）
+func loadVocabulary(db *gorm.DB, excludeID uint) (*vocabulary, error) {
+	v := &vocabulary{lookup: make(map[string]map[string]string), values: make(map[string][]string)}
+	var terms []models.VocabTerm
+	if err := db.Where("id <> ?", excludeID).Order("sort_order asc, id asc").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range terms {
+		v.add(t.Field, t.Value, termAliases(t)...)
+	}
+	// 组织架构中的职位名称同样是合法职务
+	var positions []models.OrgPosition
+	if err := db.Select("name").Order("sort_order asc, id asc").Find(&positions).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		v.add(vocabPosition, p.Name)
+	}
+	return v, nil
+}
+
+// normalize 将输入归一为标准值；空值原样返回
+func (v *vocabulary) normalize(field, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if field == "year" {
+		return normalizeYear(value)
+	}
+	if s, ok := v.lookup[field][vocabKey(value)]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("%s无效：%s，可选值为 %s", vocabFieldLabels[field], value, strings.Join(v.values[field], "、"))
+}
+
+// normalizeYear 入学年份归一为四位数字，接受 "2024级"、"24级" 等写法
+func normalizeYear(value string) (string, error) {
+	digits := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), "级届年"))
+	if len(digits) == 2 {
+		digits = "20" + digits
+	}
+	y, err := strconv.Atoi(digits)
+	if err != nil || len(digits) != 4 || y < 2000 || y > time.Now().Year()+1 {
+		return "", fmt.Errorf("入学年份无效：%s", value)
+	}
+	return digits, nil
+}
+
+// memberVocabFields 成员写入时受词表约束的字段，nil 表示本次未写入
+type memberVocabFields struct {
+	Sex, Position, Year, Direction *string
+}
+
+// normalizeMember 就地归一各字段，返回第一个无效字段的错误
+func (v *vocabulary) normalizeMember(f memberVocabFields) error {
+	for _, item := range []struct {
+		field string
+		value *string
+	}{{vocabSex, f.Sex}, {vocabPosition, f.Position}, {"year", f.Year}, {vocabDirection, f.Direction}} {
+		if item.value == nil {
+			continue
+		}
+		s, err := v.normalize(item.field, *item.value)
+		if err != nil {
+			return err
+		}
+		*item.value = s
+	}
+	return nil
+}
+
+// normalizeMemberInput 读取词表并归一成员字段，供各写入接口使用
+func normalizeMemberInput(f memberVocabFields) error {
+	v, err := loadVocabulary(config.DB, 0)
+	if err != nil {
+		return err
+	}
+	return v.normalizeMember(f)
+}
+
+func vocabTermResponse(t models.VocabTerm) echo.Map {
+	return echo.Map{
+		"id":         t.ID,
+		"field":      t.Field,
+		"value":      t.Value,
+		"aliases":    termAliases(t),
+		"sort_order": t.SortOrder,
+	}
+}
+
+// checkVocabTerm 校验词条：字段合法、标准值非空，且各写法不与同字段的其他词条冲突
+func checkVocabTerm(t models.VocabTerm) error {
+	if !containsString(vocabFields, t.Field) {
+		return fmt.Errorf("字段只能为 %s", strings.Join(vocabFields, "、"))
+	}
+	if strings.TrimSpace(t.Value) == "" {
+		return errors.New("标准值不能为空")
+	}
+	v, err := loadVocabulary(config.DB, t.ID)
+	if err != nil {
+		return err
+	}
+	for _, s := range append([]string{t.Value}, termAliases(t)...) {
+		if _, ok := v.lookup[t.Field][vocabKey(s)]; ok {
+			return fmt.Errorf("%w：%s", errVocabConflict, s)
+		}
+	}
+	return nil
+}
+
+func vocabTermError(c echo.Context, err error) error {
+	if errors.Is(err, errVocabConflict) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+}
+
+// ListVocabularies 公开的词表，供前端生成选项；组织架构中的职位同样可作为职务
+func ListVocabularies(c echo.Context) error {
+	fields := vocabFields
+	if field := c.QueryParam("field"); field != "" {
+		fields = []string{field}
+	}
+	var terms []models.VocabTerm
+	if err := config.DB.Where("field IN ?", fields).Order("sort_order asc, id asc").Find(&terms).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	result := make(map[string][]echo.Map)
+	for _, field := range fields {
+		result[field] = []echo.Map{}
+	}
+	for _, t := range terms {
+		result[t.Field] = append(result[t.Field], vocabTermResponse(t))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+type vocabTermRequest struct {
+	Value     *string   `json:"value"`
+	Aliases   *[]string `json:"aliases"`
+	SortOrder *int      `json:"sort_order"`
+}
+
+func (req vocabTermRequest) apply(t *models.VocabTerm) {
+	if req.Value != nil {
+		t.Value = strings.TrimSpace(*req.Value)
+	}
+	if req.Aliases != nil {
+		aliases := []string{}
+		for _, a := range *req.Aliases {
+			if a = strings.TrimSpace(a); a != "" && vocabKey(a) != vocabKey(t.Value) {
+				aliases = append(aliases, a)
+			}
+		}
+		raw, _ := json.Marshal(aliases)
+		t.Aliases = string(raw)
+	}
+	if req.SortOrder != nil {
+		t.SortOrder = *req.SortOrder
+	}
+}
+
+// CreateVocabTerm 新增标准值及其别名
+func CreateVocabTerm(c echo.Context) error {
+	var req vocabTermRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+	t := models.VocabTerm{Field: c.Param("field"), Aliases: "[]"}
+	req.apply(&t)
+	if err := checkVocabTerm(t); err != nil {
+		return vocabTermError(c, err)
+	}
+	if err := config.DB.Create(&t).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "创建词条失败"})
+	}
+	recordAudit(c, AuditVocabCreate, "vocab", t.Field+":"+t.Value, nil, vocabTermResponse(t))
+	return c.JSON(http.StatusCreated, vocabTermResponse(t))
+}
+
+// UpdateVocabTerm 修改词条；标准值改名时同步更新成员数据，旧名称自动加入别名
+func UpdateVocabTerm(c echo.Context) error {
+	var t models.VocabTerm
+	if err := config.DB.Where("field = ?", c.Param("field")).First(&t, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "词条不存在"})
+	}
+	var req vocabTermRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "请求格式错误"})
+	}
+
+	before := vocabTermResponse(t)
+	oldValue := t.Value
+	req.apply(&t)
+	if t.Value != oldValue {
+		aliases := termAliases(t)
+		if !containsString(aliases, oldValue) && vocabKey(oldValue) != vocabKey(t.Value) {
+			aliases = append(aliases, oldValue)
+		}
+		raw, _ := json.Marshal(aliases)
+		t.Aliases = string(raw)
+	}
+	if err := checkVocabTerm(t); err != nil {
+		return vocabTermError(c, err)
+	}
+
+	var renamed int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&t).Error; err != nil {
+			return err
+		}
+		if t.Value == oldValue {
+			return nil
+		}
+		result := tx.Model(&models.ClubMember{}).Where(t.Field+" = ?", oldValue).Update(t.Field, t.Value)
+		renamed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "更新失败"})
+	}
+
+	after := vocabTermResponse(t)
+	after["members_updated"] = renamed
+	recordAudit(c, AuditVocabUpdate, "vocab", t.Field+":"+oldValue, before, after)
+	if renamed > 0 {
+		syncMembersAsync()
+	}
+	return c.JSON(http.StatusOK, after)
+}
+
+// DeleteVocabTerm 删除仍未被任何成员使用的词条
+func DeleteVocabTerm(c echo.Context) error {
+	var t models.VocabTerm
+	if err := config.DB.Where("field = ?", c.Param("field")).First(&t, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "词条不存在"})
+	}
+	var used int64
+	config.DB.Model(&models.ClubMember{}).Where(t.Field+" = ?", t.Value).Count(&used)
+	if used > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": fmt.Sprintf("仍有 %d 名成员使用该值，请先修改", used)})
+	}
+	if err := config.DB.Delete(&t).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "删除失败"})
+	}
+	recordAudit(c, AuditVocabDelete, "vocab", t.Field+":"+t.Value, vocabTermResponse(t), nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// vocabMigrationItem 迁移报告中的一项：Mapped 为 false 的值无法识别，需要人工处理
+type vocabMigrationItem struct {
+	CN     string `json:"cn"`
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Mapped bool   `json:"mapped"`
+}
+
+// MigrateVocabularies 将成员现有的性别、职务、入学年份、方向映射为标准值；
+// apply 为 false 时只生成报告。无法识别的值保持不变，列入报告待人工处理
+func MigrateVocabularies(apply bool, actorCN string) ([]vocabMigrationItem, error) {
+	items := []vocabMigrationItem{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		v, err := loadVocabulary(tx, 0)
+		if err != nil {
+			return err
+		}
+		var members []models.ClubMember
+		if err := tx.Select("cn", "sex", "position", "year", "direction").Order("cn asc").Find(&members).Error; err != nil {
+			return err
+		}
+		for _, m := range members {
+			updates := map[string]interface{}{}
+			for field, value := range map[string]string{vocabSex: m.Sex, vocabPosition: m.Position, "year": m.Year, vocabDirection: m.Direction} {
+				if value == "" {
+					continue
+				}
+				to, err := v.normalize(field, value)
+				switch {
+				case err != nil:
+					items = append(items, vocabMigrationItem{CN: m.CN, Field: field, From: value})
+				case to != value:
+					items = append(items, vocabMigrationItem{CN: m.CN, Field: field, From: value, To: to, Mapped: true})
+					updates[field] = to
+				}
+			}
+			if apply && len(updates) > 0 {
+				if err := tx.Model(&models.ClubMember{}).Where("cn = ?", m.CN).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if !apply || len(items) == 0 {
+			return nil
+		}
+		after, _ := json.Marshal(vocabMigrationSummary(items))
+		return tx.Create(&models.AuditLog{
+			ActorCN:    actorCN,
+			Action:     AuditVocabMigrate,
+			TargetType: "vocab",
+			Target:     "club_members",
+			After:      string(after),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortVocabMigration(items)
+	if apply {
+		syncMembersAsync()
+	}
+	return items, nil
+}
+
+// sortVocabMigration 报告按成员、字段排序，便于复核
+func sortVocabMigration(items []vocabMigrationItem) {
+	order := map[string]int{vocabSex: 0, vocabPosition: 1, "year": 2, vocabDirection: 3}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].CN != items[j].CN {
+			return items[i].CN < items[j].CN
+		}
+		return order[items[i].Field] < order[items[j].Field]
+	})
+}
+
+func vocabMigrationSummary(items []vocabMigrationItem) echo.Map {
+	mapped := 0
+	for _, it := range items {
+		if it.Mapped {
+			mapped++
+		}
+	}
+	return echo.Map{"mapped": mapped, "unmapped": len(items) - mapped}
+}
+
+// vocabMigrationCSV 生成便于人工复核的 CSV 报告
+func vocabMigrationCSV(items []vocabMigrationItem) ([]byte, error) {
+	columns := []exportColumn{{"cn", "成员"}, {"field", "字段"}, {"from", "原值"}, {"to", "标准值"}, {"result", "结果"}}
+	records := make([]map[string]string, 0, len(items))
+	for _, it := range items {
+		result := "待人工处理"
+		if it.Mapped {
+			result = "已归一"
+		}
+		records = append(records, map[string]string{
+			"cn": it.CN, "field": vocabFieldLabels[it.Field], "from": it.From, "to": it.To, "result": result,
+		})
+	}
+	return memberExportCSV(columns, records)
+}
+
+// MigrateVocabularyData 按词表归一成员数据；?dry_run=true 只返回报告，?format=csv 下载 CSV 报告
+func MigrateVocabularyData(c echo.Context) error {
+	actorCN, _ := c.Get("user_cn").(string)
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+
+	items, err := MigrateVocabularies(!dryRun, actorCN)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "迁移失败，未修改任何数据"})
+	}
+
+	if c.QueryParam("format") == "csv" {
+		data, err := vocabMigrationCSV(items)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "生成 CSV 失败"})
+		}
+		filename := "vocab_migration_" + time.Now().Format("20060102") + ".csv"
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+	}
+	resp := vocabMigrationSummary(items)
+	resp["dry_run"] = dryRun
+	resp["items"] = items
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RunVocabMigration 命令行迁移入口（-migrate-vocab），CSV 复核报告写入当前目录，返回报告路径
+func RunVocabMigration(apply bool) (string, error) {
+	items, err := MigrateVocabularies(apply, "system")
+	if err != nil {
+		return "", err
+	}
+	data, err := vocabMigrationCSV(items)
+	if err != nil {
+		return "", err
+	}
+	path := "vocab_migration_" + time.Now().Format("20060102_150405") + ".csv"
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	summary := vocabMigrationSummary(items)
+	action := "可归一"
+	if apply {
+		action = "已归一"
+	}
+	fmt.Printf("%s %d 项，%d 项无法识别需人工处理\n", action, summary["mapped"], summary["unmapped"])
+	return path, nil
+}
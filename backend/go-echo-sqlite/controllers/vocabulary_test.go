@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSeedVocabulariesAddsMissingPositions(t *testing.T) {
+	newTestDB(t)
+	// 旧版本只写入了 组员，管理员又把 副组长 删除过
+	config.DB.Create(&models.VocabTerm{Field: vocabPosition, Value: "组员", Aliases: `["成员"]`})
+	removed := models.VocabTerm{Field: vocabPosition, Value: "副组长"}
+	config.DB.Create(&removed)
+	config.DB.Delete(&removed)
+
+	if err := SeedVocabularies(); err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	config.DB.Model(&models.VocabTerm{}).Where("field = ?", vocabPosition).Order("id asc").Pluck("value", &values)
+	if strings.Join(values, ",") != "组员,组长" {
+		t.Fatalf("position terms: %v", values)
+	}
+
+	// 再次启动不会重复写入
+	if err := SeedVocabularies(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.VocabTerm{}).Where("field = ?", vocabPosition).Count(&count)
+	if count != 3 {
+		t.Fatalf("terms after reseed: %d", count)
+	}
+
+	config.DB.Create(&models.ClubMember{CN: "leader", Password: "x", Position: "队长", IsMember: true})
+	items, err := MigrateVocabularies(true, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || !items[0].Mapped || items[0].To != "组长" {
+		t.Fatalf("migration: %+v", items)
+	}
+}
+
+func TestTenureWritesNormalizePositionAndDirection(t *testing.T) {
+	newTestDB(t)
+	if err := SeedVocabularies(); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Create(&models.ClubMember{CN: "alice", Password: "x", IsMember: true})
+
+	call := func(h echo.HandlerFunc, method, body, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		if err := h(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	rec := call(CreateTenure, http.MethodPost, `{"cn":"alice","academic_year":"2024","position":"队长","direction":"MAD"}`, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		ID        uint   `json:"id"`
+		Position  string `json:"position"`
+		Direction string `json:"direction"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.Position != "组长" || created.Direction != "动画" {
+		t.Fatalf("create not normalized: %+v", created)
+	}
+
+	rec = call(UpdateTenure, http.MethodPut, `{"position":"副队长","direction":"3d"}`, strconv.Itoa(int(created.ID)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"position":"副组长"`) || !strings.Contains(rec.Body.String(), `"direction":"三维"`) {
+		t.Fatalf("update: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = call(CreateTenure, http.MethodPost, `{"cn":"alice","academic_year":"2023","position":"打黑工的"}`, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown position: %d %s", rec.Code, rec.Body)
+	}
+}
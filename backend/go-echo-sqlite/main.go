@@ -1,12 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/config"
+	"seventhcenturyvideogroup/backend/go-echo-sqlite/controllers"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/routes"
 	"seventhcenturyvideogroup/backend/go-echo-sqlite/services"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -35,6 +38,10 @@ func debugRAGDatabase() {
 }
 
 func main() {
+	rotateJWTKey := flag.Bool("rotate-jwt-key", false, "轮换JWT签名密钥后退出")
+	migrateVocab := flag.String("migrate-vocab", "", "按受控词表归一成员数据后退出：preview 只生成报告，apply 写入")
+	flag.Parse()
+
 	// 加载.env文件（支持多个位置）
 	envPaths := []string{
 		".env",
@@ -64,6 +71,8 @@ func main() {
 	}
 
 	e := echo.New()
+	// 客户端 IP 只从连接地址或受信任代理的 X-Forwarded-For 获取（见 TRUSTED_PROXIES）
+	e.IPExtractor = controllers.IPExtractor()
 
 	// 中间件配置
 	e.Use(middleware.Logger())
@@ -76,6 +85,55 @@ func main() {
 	// 初始化数据库
 	config.InitDB()
 
+	// 同步内置角色与权限
+	if err := controllers.SeedRBAC(); err != nil {
+		log.Fatalf("✗ 初始化角色权限失败: %v", err)
+	}
+	// 管理员强制两步验证：已有的未验证会话与令牌全部作废
+	if sessions, tokens, err := controllers.RevokeAdminSessionsWithoutMFA(); err != nil {
+		log.Fatalf("✗ 撤销管理员会话失败: %v", err)
+	} else if sessions+tokens > 0 {
+		fmt.Printf("✓ 管理员须启用两步验证，已撤销 %d 个会话与 %d 个个人令牌\n", sessions, tokens)
+	}
+	if n, err := controllers.TrashRelationsOfDeletedMembers(); err != nil {
+		log.Fatalf("✗ 处理已删除成员的关联记录失败: %v", err)
+	} else if n > 0 {
+		fmt.Printf("✓ 已将回收站中成员的 %d 条任职、角色与外部身份记录移入回收站\n", n)
+	}
+	if err := controllers.MigrateMemberStatuses(); err != nil {
+		log.Fatalf("✗ 迁移成员在役状态失败: %v", err)
+	}
+	if err := controllers.SeedOrgPositions(); err != nil {
+		log.Fatalf("✗ 初始化组织架构失败: %v", err)
+	}
+	if err := controllers.SeedVocabularies(); err != nil {
+		log.Fatalf("✗ 初始化受控词表失败: %v", err)
+	}
+
+	if *rotateJWTKey {
+		key, previousRetireAt, err := controllers.RotateSigningKey()
+		if err != nil {
+			log.Fatalf("✗ 轮换JWT签名密钥失败: %v", err)
+		}
+		fmt.Printf("✓ 已启用新的JWT签名密钥: %s\n", key.KID)
+		if previousRetireAt != nil {
+			fmt.Printf("  原签名密钥将于 %s 失效，运行中的服务会在 JWT_KEY_RELOAD_INTERVAL 内自动切换\n", previousRetireAt.Format(time.RFC3339))
+		}
+		return
+	}
+
+	if *migrateVocab != "" {
+		if *migrateVocab != "preview" && *migrateVocab != "apply" {
+			log.Fatalf("✗ -migrate-vocab 只能为 preview 或 apply")
+		}
+		report, err := controllers.RunVocabMigration(*migrateVocab == "apply")
+		if err != nil {
+			log.Fatalf("✗ 归一成员数据失败: %v", err)
+		}
+		fmt.Printf("✓ 复核报告已写入: %s\n", report)
+		return
+	}
+
 	// 初始化RAG系统
 	fmt.Println("\n========== 正在初始化RAG系统 ==========")
 	ragService := services.NewRAGService()
@@ -89,7 +147,19 @@ func main() {
 
 	// 打印数据库诊断信息
 	debugRAGDatabase()
-	fmt.Println("==========================================\n")
+	fmt.Print("==========================================\n\n")
+
+	// 定时清理过期备忘码
+	controllers.StartMemoryCodeCleanup()
+
+	// 定时清理过期的认证尝试记录
+	controllers.StartAuthAttemptCleanup()
+
+	// 定时彻底删除超过保留期的回收站记录
+	controllers.StartTrashPurge()
+
+	// 开启 ROLLOVER_AUTO 时在新学年开始后自动换届
+	controllers.StartRolloverSchedule()
 
 	// 静态文件服务 - 提供头像图片访问
 	e.Static("/pics", "pics")
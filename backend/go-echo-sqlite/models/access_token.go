@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccount 供自动化程序（如 ai-backend）使用的非成员身份
+type ServiceAccount struct {
+	gorm.Model
+	Name        string     `gorm:"column:name;uniqueIndex"`
+	Description string     `gorm:"column:description"`
+	CreatedBy   string     `gorm:"column:created_by"`
+	DisabledAt  *time.Time `gorm:"column:disabled_at"`
+}
+
+// AccessToken 长期有效的访问令牌，属于某个成员（个人令牌）或服务账号
+type AccessToken struct {
+	gorm.Model
+	Name       string     `gorm:"column:name"`
+	OwnerType  string     `gorm:"column:owner_type;index:idx_access_token_owner"` // member / service
+	Owner      string     `gorm:"column:owner;index:idx_access_token_owner"`      // 成员 CN 或服务账号名
+	TokenHash  string     `gorm:"column:token_hash;uniqueIndex"`                  // 令牌的 SHA-256，不保存明文
+	Hint       string     `gorm:"column:hint"`                                    // 明文前缀，便于辨认
+	Scopes     string     `gorm:"column:scopes"`                                  // 空格分隔的作用域
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`                              // 为空表示不过期
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	LastUsedIP string     `gorm:"column:last_used_ip"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	CreatedBy  string     `gorm:"column:created_by"`
+	MFA        bool       `gorm:"column:mfa"` // 创建时所用的会话是否通过了两步验证
+}
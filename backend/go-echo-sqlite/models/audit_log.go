@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditLog 特权与 MCP 写操作的审计记录，只允许追加
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ActorCN    string    `json:"actor_cn" gorm:"column:actor_cn;index"`
+	Action     string    `json:"action" gorm:"column:action;index"`     // 如 member.update
+	TargetType string    `json:"target_type" gorm:"column:target_type"` // member / profile / activity ...
+	Target     string    `json:"target" gorm:"column:target;index"`     // 目标标识，如成员 CN
+	Before     string    `json:"before" gorm:"column:before;type:text"` // 变更前（JSON）
+	After      string    `json:"after" gorm:"column:after;type:text"`   // 变更后（JSON）
+	IP         string    `json:"ip" gorm:"column:ip"`
+	UserAgent  string    `json:"user_agent" gorm:"column:user_agent"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+var ErrAuditLogImmutable = errors.New("审计日志不可修改或删除")
+
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AuthAttempt 登录、改密、重置等认证尝试记录，用于限流和账号锁定
+type AuthAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	Action    string    `gorm:"column:action"` // login / forgot_password / change_password / reset_password / unlock
+	CN        string    `gorm:"column:cn;index"`
+	IP        string    `gorm:"column:ip;index"`
+	Success   bool      `gorm:"column:success"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -14,4 +14,10 @@ type ClubMember struct {
 	Status    string `gorm:"column:status"`                 // 在役状态
 	IsMember  bool   `gorm:"column:is_member;default:true"` // 新增：是否为社团成员
 	Remark    string `gorm:"column:remark"`
+
+	// 两步验证（TOTP）
+	TOTPSecret        string `gorm:"column:totp_secret" json:"-"`         // Base32 密钥，启用前为待确认状态
+	TOTPEnabled       bool   `gorm:"column:totp_enabled;default:false"`   // 是否已启用
+	TOTPLastStep      int64  `gorm:"column:totp_last_step" json:"-"`      // 最近一次通过验证的时间步，防止验证码重放
+	TOTPRecoveryCodes string `gorm:"column:totp_recovery_codes" json:"-"` // 恢复码 SHA-256 列表（JSON）
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExternalIdentity 外部 OIDC 身份（issuer + subject）与成员的绑定关系
+type ExternalIdentity struct {
+	gorm.Model
+	Issuer     string     `gorm:"column:issuer;uniqueIndex:idx_external_identity_subject"`
+	Subject    string     `gorm:"column:subject;uniqueIndex:idx_external_identity_subject"`
+	CN         string     `gorm:"column:cn;index"`
+	Email      string     `gorm:"column:email"`
+	Name       string     `gorm:"column:name"` // 外部身份的显示名
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+}
+
+// OIDCLoginState 进行中的 OIDC 授权请求，回调时按 state 取回 PKCE verifier 与 nonce
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	State        string    `gorm:"column:state;uniqueIndex"`
+	CodeVerifier string    `gorm:"column:code_verifier"`
+	Nonce        string    `gorm:"column:nonce"`
+	LinkCN       string    `gorm:"column:link_cn"`      // 非空表示已登录成员发起的绑定，而非登录
+	BrowserHash  string    `gorm:"column:browser_hash"` // 发起授权的浏览器所持 cookie 的哈希，回调时核对
+	ExpiresAt    time.Time `gorm:"column:expires_at;index"`
+	CreatedAt    time.Time
+}
+
+// OIDCLoginTicket 外部登录成功后交给前端的一次性凭据，前端用它换取登录结果，令牌不出现在 URL 中
+type OIDCLoginTicket struct {
+	ID          uint      `gorm:"primaryKey"`
+	TicketHash  string    `gorm:"column:ticket_hash;uniqueIndex"`
+	CN          string    `gorm:"column:cn"`
+	BrowserHash string    `gorm:"column:browser_hash"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InvitationCode 干部签发的注册邀请码，可单次或多次使用
+type InvitationCode struct {
+	gorm.Model
+	CodeHash  string     `gorm:"column:code_hash;uniqueIndex"` // 邀请码的 SHA-256，不保存明文
+	Hint      string     `gorm:"column:hint"`                  // 明文前几位，便于干部辨认
+	MaxUses   int        `gorm:"column:max_uses"`
+	UsedCount int        `gorm:"column:used_count"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	Year      string     `gorm:"column:year"`      // 预设年级，注册时覆盖用户填写的值
+	Direction string     `gorm:"column:direction"` // 预设方向
+	Role      string     `gorm:"column:role"`      // 注册后额外分配的角色，可为空
+	Note      string     `gorm:"column:note"`
+	CreatedBy string     `gorm:"column:created_by"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// MemberAlias 成员改名后保留的旧 CN，旧的个人主页链接据此跳转到新 CN
+type MemberAlias struct {
+	gorm.Model
+	OldCN     string `gorm:"column:old_cn;uniqueIndex"`
+	CN        string `gorm:"column:cn;index"` // 当前 CN
+	RenamedBy string `gorm:"column:renamed_by"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// 字段可见范围
+const (
+	VisibilityPublic   = "public"   // 所有人，包括未登录的访客与 AI 知识库
+	VisibilityMembers  = "members"  // 社团成员
+	VisibilityOfficers = "officers" // 干部（拥有 members:write 权限）
+)
+
+var Visibilities = []string{VisibilityPublic, VisibilityMembers, VisibilityOfficers}
+
+// PrivacyFields 成员可以设置可见范围的字段；CN、职务与在役状态始终公开
+var PrivacyFields = []string{"sex", "year", "direction", "remark", "avatar", "bili_uid", "signature", "representative_work", "other"}
+
+// DefaultVisibility 成员未设置时的可见范围
+var DefaultVisibility = map[string]string{
+	"sex":                 VisibilityMembers,
+	"year":                VisibilityPublic,
+	"direction":           VisibilityPublic,
+	"remark":              VisibilityMembers,
+	"avatar":              VisibilityPublic,
+	"bili_uid":            VisibilityPublic,
+	"signature":           VisibilityPublic,
+	"representative_work": VisibilityPublic,
+	"other":               VisibilityMembers,
+}
+
+// MemberPrivacy 成员各字段的可见范围，Settings 只保存与默认值不同的字段
+type MemberPrivacy struct {
+	gorm.Model
+	CN       string `gorm:"column:cn;uniqueIndex"`
+	Settings string `gorm:"column:settings;type:text"` // 字段 -> 可见范围（JSON）
+}
+
+// Visibility 合并默认值后的完整设置；p 为 nil 时返回默认值
+func (p *MemberPrivacy) Visibility() map[string]string {
+	settings := make(map[string]string, len(DefaultVisibility))
+	for field, v := range DefaultVisibility {
+		settings[field] = v
+	}
+	if p == nil || p.Settings == "" {
+		return settings
+	}
+	var saved map[string]string
+	if err := json.Unmarshal([]byte(p.Settings), &saved); err != nil {
+		return settings
+	}
+	for field, v := range saved {
+		if _, ok := settings[field]; ok {
+			settings[field] = v
+		}
+	}
+	return settings
+}
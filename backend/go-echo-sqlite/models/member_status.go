@@ -0,0 +1,50 @@
+package models
+
+import "gorm.io/gorm"
+
+// 成员在役状态，即 ClubMember.Status 的取值
+const (
+	MemberStatusApplicant = "applicant" // 申请中（访客，等待入社审核）
+	MemberStatusActive    = "active"    // 在役
+	MemberStatusOnLeave   = "on_leave"  // 暂离
+	MemberStatusRetired   = "retired"   // 已退居幕后
+	MemberStatusGraduated = "graduated" // 已毕业
+	MemberStatusAlumni    = "alumni"    // 校友
+)
+
+// MemberStatuses 按生命周期顺序排列的全部状态
+var MemberStatuses = []string{
+	MemberStatusApplicant,
+	MemberStatusActive,
+	MemberStatusOnLeave,
+	MemberStatusRetired,
+	MemberStatusGraduated,
+	MemberStatusAlumni,
+}
+
+var memberStatusLabels = map[string]string{
+	MemberStatusApplicant: "申请中",
+	MemberStatusActive:    "在役",
+	MemberStatusOnLeave:   "暂离",
+	MemberStatusRetired:   "已退居幕后",
+	MemberStatusGraduated: "已毕业",
+	MemberStatusAlumni:    "校友",
+}
+
+// MemberStatusLabel 返回状态的中文名称，未知状态原样返回
+func MemberStatusLabel(status string) string {
+	if label, ok := memberStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// StatusChange 成员状态变更记录，CreatedAt 即变更时间
+type StatusChange struct {
+	gorm.Model
+	CN         string `gorm:"column:cn;index"`
+	FromStatus string `gorm:"column:from_status"`
+	ToStatus   string `gorm:"column:to_status"`
+	Reason     string `gorm:"column:reason"`
+	ChangedBy  string `gorm:"column:changed_by"`
+}
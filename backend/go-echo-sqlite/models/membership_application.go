@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MembershipApplication 访客的入社申请，干部审核通过后成为社团成员
+type MembershipApplication struct {
+	gorm.Model
+	CN         string     `gorm:"column:cn;index"`
+	Status     string     `gorm:"column:status;index"` // pending / approved / rejected
+	Message    string     `gorm:"column:message"`      // 申请人留言
+	Reason     string     `gorm:"column:reason"`       // 拒绝原因
+	ReviewedBy string     `gorm:"column:reviewed_by"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at"`
+}
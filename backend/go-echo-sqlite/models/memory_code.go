@@ -6,11 +6,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// MemoryCode 管理员为指定成员生成的一次性备忘码，用于忘记密码时自助重置
 type MemoryCode struct {
-	ID        uint           `json:"id" gorm:"primary_key"`
-	Code      string         `json:"code" gorm:"not null"`
-	Date      string         `json:"date" gorm:"not null"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primary_key"`
+	CN         string         `json:"cn" gorm:"column:cn;index"`
+	Code       string         `json:"-" gorm:"not null"` // 备忘码的 SHA-256，不保存明文
+	Date       string         `json:"date" gorm:"not null"`
+	ExpiresAt  time.Time      `json:"expires_at" gorm:"column:expires_at;index"`
+	ConsumedAt *time.Time     `json:"consumed_at" gorm:"column:consumed_at"`
+	IssuedBy   string         `json:"issued_by" gorm:"column:issued_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
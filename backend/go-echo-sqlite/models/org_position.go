@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+// 职位所属的条线
+const (
+	OrgLineGeneral = "general" // 全社
+	OrgLineMAD     = "mad"     // MAD 线
+	OrgLineMMD     = "mmd"     // MMD 线
+)
+
+var OrgLines = []string{OrgLineGeneral, OrgLineMAD, OrgLineMMD}
+
+// OrgLineLabel 条线的中文名称
+func OrgLineLabel(line string) string {
+	switch line {
+	case OrgLineGeneral:
+		return "全社"
+	case OrgLineMAD:
+		return "MAD 线"
+	case OrgLineMMD:
+		return "MMD 线"
+	}
+	return line
+}
+
+// OrgPosition 组织架构中的职位；每学年的任职以 Tenure.PositionID 关联
+type OrgPosition struct {
+	gorm.Model
+	Name        string `gorm:"column:name;index"`
+	ParentID    *uint  `gorm:"column:parent_id;index"` // 上级职位，为空表示顶层
+	Line        string `gorm:"column:line;index"`
+	SeatLimit   int    `gorm:"column:seat_limit"` // 每学年的名额，0 为不限
+	SortOrder   int    `gorm:"column:sort_order"`
+	Description string `gorm:"column:description"`
+}
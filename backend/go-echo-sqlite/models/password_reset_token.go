@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken 管理员为指定成员签发的一次性密码重置令牌
+type PasswordResetToken struct {
+	gorm.Model
+	CN        string     `gorm:"column:cn;index"`
+	TokenHash string     `gorm:"column:token_hash;uniqueIndex"` // 令牌的 SHA-256，不保存明文
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	IssuedBy  string     `gorm:"column:issued_by"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken 登录会话及其刷新令牌（每次刷新时轮换 TokenHash）
+type RefreshToken struct {
+	gorm.Model
+	CN         string     `gorm:"column:cn;index"`
+	SessionID  string     `gorm:"column:session_id;uniqueIndex"` // 写入访问 token 的 sid
+	TokenHash  string     `gorm:"column:token_hash;uniqueIndex"` // 刷新令牌的 SHA-256，不保存明文
+	ExpiresAt  time.Time  `gorm:"column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at;index"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	MFA        bool       `gorm:"column:mfa"` // 登录时是否通过了两步验证
+	IP         string     `gorm:"column:ip"`
+	UserAgent  string     `gorm:"column:user_agent"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+// Role 角色（guest / member / officer / admin）
+type Role struct {
+	gorm.Model
+	Name        string       `gorm:"column:name;uniqueIndex"`
+	Description string       `gorm:"column:description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;"`
+}
+
+// Permission 命名权限，如 members:write
+type Permission struct {
+	gorm.Model
+	Name        string `gorm:"column:name;uniqueIndex"`
+	Description string `gorm:"column:description"`
+}
+
+// MemberRole 成员的角色分配
+type MemberRole struct {
+	gorm.Model
+	CN        string `gorm:"column:cn;index"`
+	RoleName  string `gorm:"column:role_name;index"`
+	GrantedBy string `gorm:"column:granted_by"`
+}
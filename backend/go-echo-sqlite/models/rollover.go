@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rollover 一次学年换届；Snapshot 保存受影响成员换届前后的状态及新建的记录，用于回滚
+type Rollover struct {
+	gorm.Model
+	AcademicYear string     `gorm:"column:academic_year;index"` // 新学年起始年份
+	Rules        string     `gorm:"column:rules;type:text"`     // 本次启用的规则（JSON）
+	Snapshot     string     `gorm:"column:snapshot;type:text"`  // 回滚所需的快照（JSON）
+	Report       string     `gorm:"column:report;type:text"`    // 变更明细（JSON）
+	AppliedBy    string     `gorm:"column:applied_by"`
+	RolledBackAt *time.Time `gorm:"column:rolled_back_at"`
+	RolledBackBy string     `gorm:"column:rolled_back_by"`
+}
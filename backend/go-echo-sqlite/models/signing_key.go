@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SigningKey 通过轮换生成的 JWT 签名密钥
+type SigningKey struct {
+	gorm.Model
+	KID      string     `gorm:"column:kid;uniqueIndex"`
+	Secret   string     `gorm:"column:secret"`
+	Status   string     `gorm:"column:status;index"` // active / retiring
+	RetireAt *time.Time `gorm:"column:retire_at"`    // retiring 状态下的失效时间
+	// 未配置任何密钥时自动生成；配置 JWT_SIGNING_KEYS 后自动转为 retiring
+	AutoGenerated bool `gorm:"column:auto_generated;default:false"`
+}
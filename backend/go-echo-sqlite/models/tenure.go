@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tenure 成员在某一学年的任职记录，ClubMember 只保存当前状态，历史以此为准
+type Tenure struct {
+	gorm.Model
+	CN           string `gorm:"column:cn;index"`
+	AcademicYear string `gorm:"column:academic_year;index"` // 学年起始年份，如 "2024" 表示 2024-2025 学年
+	Position     string `gorm:"column:position"`            // 组员、组长等
+	PositionID   *uint  `gorm:"column:position_id;index"`   // 组织架构中的职位，为空表示仅记录职务名称
+	Direction    string `gorm:"column:direction"`
+	Status       string `gorm:"column:status"` // 在役状态
+	Note         string `gorm:"column:note"`
+	CreatedBy    string `gorm:"column:created_by"`
+}
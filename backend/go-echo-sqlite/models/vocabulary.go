@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// VocabTerm 受控词表中的标准值，成员的性别、职务、方向写入时都会归一到这里的 Value
+type VocabTerm struct {
+	gorm.Model
+	Field     string `gorm:"column:field;index"` // sex / position / direction
+	Value     string `gorm:"column:value"`
+	Aliases   string `gorm:"column:aliases;type:text"` // 可识别的其他写法（JSON 数组）
+	SortOrder int    `gorm:"column:sort_order"`
+}
@@ -11,32 +11,130 @@ func InitRoutes(e *echo.Echo) {
 
 	// 认证相关路由（无需权限）
 	api.POST("/login", controllers.Login)
+	api.POST("/login/2fa", controllers.LoginTOTP)
+	api.POST("/refresh", controllers.RefreshSession)
 	api.POST("/register", controllers.Register)
 	api.POST("/forgot-password", controllers.ForgotPassword)
-	api.POST("/change-password", controllers.ChangePassword)
-	api.GET("/memory-code", controllers.GetMemoryCode)
+	api.POST("/reset-password", controllers.ResetPassword)
 
-	// MCP 受保护路由（需要成员权限；GET 无 cn 限制；写操作默认 cn 必须一致，管理员可强制）
-	api.POST("/mcp/register", controllers.RequireMember(controllers.MCPRegister))
-	api.GET("/mcp/club_members/:cn", controllers.RequireMember(controllers.GetClubMemberByCN))
-	api.PUT("/mcp/club_members/:cn", controllers.RequireMember(controllers.UpdateClubMemberByCN))
-	api.DELETE("/mcp/club_members/:cn", controllers.RequireMember(controllers.DeleteClubMemberByCN))
+	// 会话相关路由（需要登录）
+	api.POST("/logout", controllers.VerifyToken(controllers.Logout))
+	api.POST("/change-password", controllers.VerifyToken(controllers.ChangePassword))
 
-	// 公开路由（访客可访问）
-	api.GET("/club_members", controllers.GetClubMembers)
+	// 两步验证（enroll/confirm 同时接受登录 token 或管理员强制启用时的 enrollment_token）
+	api.POST("/2fa/enroll", controllers.EnrollTOTP)
+	api.POST("/2fa/confirm", controllers.ConfirmTOTP)
+	api.POST("/2fa/disable", controllers.VerifyToken(controllers.DisableTOTP))
+
+	// 入社申请（访客）
+	api.GET("/membership/application", controllers.VerifyToken(controllers.GetMyApplication))
+	api.POST("/membership/application", controllers.VerifyToken(controllers.SubmitApplication))
+
+	// 个人访问令牌（只能用登录会话管理）
+	api.GET("/tokens/scopes", controllers.ListAccessTokenScopes)
+	api.GET("/tokens", controllers.VerifyToken(controllers.ListMyAccessTokens))
+	api.POST("/tokens", controllers.VerifyToken(controllers.CreateMyAccessToken))
+	api.DELETE("/tokens/:id", controllers.VerifyToken(controllers.RevokeMyAccessToken))
+
+	// OIDC 外部登录与身份绑定
+	api.GET("/oidc/login", controllers.OIDCLogin)
+	api.GET("/oidc/callback", controllers.OIDCCallback)
+	api.POST("/oidc/exchange", controllers.OIDCExchange)
+	api.POST("/oidc/link", controllers.VerifyToken(controllers.OIDCLink))
+	api.GET("/oidc/identities", controllers.VerifyToken(controllers.ListMyIdentities))
+	api.DELETE("/oidc/identities/:id", controllers.VerifyToken(controllers.UnlinkMyIdentity))
+
+	// MCP 受保护路由（需要成员权限；GET 无 cn 限制；写操作默认 cn 必须一致，拥有 members:write 权限可操作他人）
+	// RequireScope 声明访问令牌调用这些接口所需的作用域
+	api.POST("/mcp/register", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequireMember(controllers.MCPRegister)))
+	api.GET("/mcp/club_members/:cn", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.RequireMember(controllers.GetClubMemberByCN)))
+	api.PUT("/mcp/club_members/:cn", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequireMember(controllers.UpdateClubMemberByCN)))
+	api.DELETE("/mcp/club_members/:cn", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequireMember(controllers.DeleteClubMemberByCN)))
+	// 改名会撤销旧会话与访问令牌，只接受登录会话
+	api.POST("/mcp/club_members/:cn/rename", controllers.VerifyToken(controllers.RenameMember))
+	// 在役状态按生命周期变更：本人可暂离、退居幕后等，其余变更需要 members:write 权限
+	api.POST("/mcp/club_members/:cn/status", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequireMember(controllers.ChangeMemberStatus)))
+	api.GET("/mcp/club_members/:cn/status-history", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.RequireMember(controllers.GetMemberStatusHistory)))
+	// 字段可见范围：本人（包括尚未入社的访客）或拥有 members:write 权限的干部可查看与修改
+	api.GET("/mcp/club_members/:cn/privacy", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.VerifyToken(controllers.GetMemberPrivacy)))
+	api.PUT("/mcp/club_members/:cn/privacy", controllers.RequireScope(controllers.ScopeProfileWrite)(controllers.VerifyToken(controllers.UpdateMemberPrivacy)))
+
+	// 公开路由（访客可访问；OptionalAuth 识别已登录的查看者，按成员隐私设置决定返回的字段）
+	api.GET("/club_members", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.GetClubMembers)))
+	api.GET("/member-status/states", controllers.ListMemberStatuses)
+	api.GET("/vocabularies", controllers.ListVocabularies)
 	api.GET("/activities", controllers.GetActivities)
 
 	// 需要社团成员权限的路由
-	api.DELETE("/club_members/:id", controllers.RequireMember(controllers.DeleteClubMember))
-	api.POST("/activities", controllers.RequireMember(controllers.CreateActivity))
+	api.DELETE("/club_members/:id", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.DeleteClubMember)))
+	api.POST("/activities", controllers.RequireScope(controllers.ScopeActivitiesWrite)(controllers.RequirePermission(controllers.PermActivitiesWrite)(controllers.CreateActivity)))
 
 	// 个人主页相关路由（需要成员权限）
-	api.GET("/member-profile/:cn", controllers.GetMemberProfile)
-	api.POST("/member-profile/:cn", controllers.RequireMember(controllers.CreateOrUpdateMemberProfile))
-	api.PUT("/member-profile/:cn", controllers.RequireMember(controllers.CreateOrUpdateMemberProfile))
-	api.DELETE("/member-profile/:cn", controllers.RequireMember(controllers.DeleteMemberProfile))
+	api.GET("/member-profile/:cn", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.GetMemberProfile)))
+	api.POST("/member-profile/:cn", controllers.RequireScope(controllers.ScopeProfileWrite)(controllers.RequireMember(controllers.CreateOrUpdateMemberProfile)))
+	api.PUT("/member-profile/:cn", controllers.RequireScope(controllers.ScopeProfileWrite)(controllers.RequireMember(controllers.CreateOrUpdateMemberProfile)))
+	api.DELETE("/member-profile/:cn", controllers.RequireScope(controllers.ScopeProfileWrite)(controllers.RequireMember(controllers.DeleteMemberProfile)))
 	api.GET("/member-profile/:cn/exists", controllers.CheckMemberProfileExists)
 
+	// 任职记录：查询公开，维护需要 members:write 权限
+	api.GET("/tenures", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.ListTenures)))
+	api.GET("/tenures/roster/:year", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.GetYearRoster)))
+	api.GET("/tenures/timeline/:cn", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.GetMemberTimeline)))
+	api.POST("/tenures", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.CreateTenure)))
+	api.PUT("/tenures/:id", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.UpdateTenure)))
+	api.DELETE("/tenures/:id", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.DeleteTenure)))
+
+	// 组织架构：架构图与职位列表公开，维护职位与任命需要 members:write 权限
+	api.GET("/org/chart", controllers.RequireScope(controllers.ScopeMembersRead)(controllers.OptionalAuth(controllers.GetOrgChart)))
+	api.GET("/org/positions", controllers.ListOrgPositions)
+	api.POST("/org/positions", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.CreateOrgPosition)))
+	api.PUT("/org/positions/:id", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.UpdateOrgPosition)))
+	api.DELETE("/org/positions/:id", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.DeleteOrgPosition)))
+	api.POST("/org/positions/:id/assignments", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.AssignOrgPosition)))
+	api.DELETE("/org/positions/:id/assignments/:cn", controllers.RequireScope(controllers.ScopeMembersWrite)(controllers.RequirePermission(controllers.PermMembersWrite)(controllers.UnassignOrgPosition)))
+
+	// 管理员路由
+	api.GET("/admin/jwt/keys", controllers.RequirePermission(controllers.PermSystemKeys)(controllers.ListJWTKeys))
+	api.POST("/admin/jwt/rotate", controllers.RequirePermission(controllers.PermSystemKeys)(controllers.RotateJWTKey))
+	api.GET("/admin/sessions/:cn", controllers.RequirePermission(controllers.PermSessionsManage)(controllers.ListSessions))
+	api.POST("/admin/sessions/:cn/revoke", controllers.RequirePermission(controllers.PermSessionsManage)(controllers.RevokeSessions))
+	api.GET("/admin/roles", controllers.RequirePermission(controllers.PermRolesManage)(controllers.ListRoles))
+	api.GET("/admin/members/:cn/roles", controllers.RequirePermission(controllers.PermRolesManage)(controllers.GetMemberRoles))
+	api.POST("/admin/members/:cn/roles", controllers.RequirePermission(controllers.PermRolesManage)(controllers.AssignMemberRole))
+	api.DELETE("/admin/members/:cn/roles/:role", controllers.RequirePermission(controllers.PermRolesManage)(controllers.RemoveMemberRole))
+	api.GET("/admin/audit", controllers.RequirePermission(controllers.PermAuditRead)(controllers.GetAuditLogs))
+	api.POST("/admin/password-resets", controllers.RequirePermission(controllers.PermPasswordsReset)(controllers.IssuePasswordReset))
+	api.POST("/admin/memory-codes", controllers.RequirePermission(controllers.PermPasswordsReset)(controllers.IssueMemoryCode))
+	api.GET("/admin/lockouts", controllers.RequirePermission(controllers.PermAccountsUnlock)(controllers.ListLockouts))
+	api.POST("/admin/lockouts/:cn/unlock", controllers.RequirePermission(controllers.PermAccountsUnlock)(controllers.UnlockAccount))
+	api.DELETE("/admin/members/:cn/2fa", controllers.RequirePermission(controllers.PermMFAReset)(controllers.ResetMemberTOTP))
+	api.GET("/admin/invitations", controllers.RequirePermission(controllers.PermInvitationsManage)(controllers.ListInvitations))
+	api.POST("/admin/invitations", controllers.RequirePermission(controllers.PermInvitationsManage)(controllers.CreateInvitation))
+	api.DELETE("/admin/invitations/:id", controllers.RequirePermission(controllers.PermInvitationsManage)(controllers.RevokeInvitation))
+	api.GET("/admin/applications", controllers.RequirePermission(controllers.PermMembersApprove)(controllers.ListApplications))
+	api.POST("/admin/applications/:id/approve", controllers.RequirePermission(controllers.PermMembersApprove)(controllers.ApproveApplication))
+	api.POST("/admin/applications/:id/reject", controllers.RequirePermission(controllers.PermMembersApprove)(controllers.RejectApplication))
+	api.GET("/admin/service-accounts", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.ListServiceAccounts))
+	api.POST("/admin/service-accounts", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.CreateServiceAccount))
+	api.DELETE("/admin/service-accounts/:name", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.DisableServiceAccount))
+	api.GET("/admin/service-accounts/:name/tokens", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.ListServiceAccountTokens))
+	api.POST("/admin/service-accounts/:name/tokens", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.CreateServiceAccountToken))
+	api.DELETE("/admin/service-accounts/:name/tokens/:id", controllers.RequirePermission(controllers.PermServiceAccounts)(controllers.RevokeServiceAccountToken))
+	api.POST("/admin/members/import", controllers.RequirePermission(controllers.PermMembersWrite)(controllers.ImportMembers))
+	api.GET("/admin/members/export", controllers.RequirePermission(controllers.PermMembersWrite)(controllers.ExportMembers))
+	api.GET("/admin/trash/:entity", controllers.RequirePermission(controllers.PermTrashManage)(controllers.ListTrash))
+	api.POST("/admin/trash/:entity/:id/restore", controllers.RequirePermission(controllers.PermTrashManage)(controllers.RestoreTrash))
+	api.DELETE("/admin/trash/:entity/:id", controllers.RequirePermission(controllers.PermTrashManage)(controllers.PurgeTrash))
+	api.GET("/admin/rollovers", controllers.RequirePermission(controllers.PermRolloverManage)(controllers.ListRollovers))
+	api.POST("/admin/rollovers/preview", controllers.RequirePermission(controllers.PermRolloverManage)(controllers.PreviewRollover))
+	api.POST("/admin/rollovers", controllers.RequirePermission(controllers.PermRolloverManage)(controllers.ApplyRollover))
+	api.GET("/admin/rollovers/:id/report", controllers.RequirePermission(controllers.PermRolloverManage)(controllers.GetRolloverReport))
+	api.POST("/admin/rollovers/:id/rollback", controllers.RequirePermission(controllers.PermRolloverManage)(controllers.RollbackRollover))
+	api.POST("/admin/vocabularies/migrate", controllers.RequirePermission(controllers.PermVocabManage)(controllers.MigrateVocabularyData))
+	api.POST("/admin/vocabularies/:field", controllers.RequirePermission(controllers.PermVocabManage)(controllers.CreateVocabTerm))
+	api.PUT("/admin/vocabularies/:field/:id", controllers.RequirePermission(controllers.PermVocabManage)(controllers.UpdateVocabTerm))
+	api.DELETE("/admin/vocabularies/:field/:id", controllers.RequirePermission(controllers.PermVocabManage)(controllers.DeleteVocabTerm))
+
 	// RAG AI助手相关路由
 	api.POST("/rag/initialize", controllers.InitializeRAG)     // 初始化RAG系统
 	api.POST("/rag/refresh", controllers.RefreshDocuments)     // 热更新知识库
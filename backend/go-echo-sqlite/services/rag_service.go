@@ -847,7 +847,7 @@ func (r *RAGService) RefreshDocuments() error {
 	r.lastUpdateTime = time.Now()
 
 	fmt.Printf("✓ 知识库热更新完成 (更新: %d 个文件, 耗时: %.2fs)\n", updatedCount, time.Since(startTime).Seconds())
-	fmt.Println("==========================================\n")
+	fmt.Print("==========================================\n\n")
 
 	return nil
 }
@@ -880,6 +880,14 @@ func (r *RAGService) SyncMembersToMarkdown() error {
 		return fmt.Errorf("获取成员信息失败: %v", err)
 	}
 
+	// 知识库对所有人可见，只收录成员设为公开的字段
+	var privacies []models.MemberPrivacy
+	db.Find(&privacies)
+	privacyByCN := make(map[string]*models.MemberPrivacy, len(privacies))
+	for i := range privacies {
+		privacyByCN[privacies[i].CN] = &privacies[i]
+	}
+
 	// 生成markdown内容
 	var content strings.Builder
 	content.WriteString("---\n")
@@ -901,14 +909,21 @@ func (r *RAGService) SyncMembersToMarkdown() error {
 
 	// 按入社时间排序
 	for i, member := range members {
+		public := privacyByCN[member.CN].Visibility()
 		content.WriteString(fmt.Sprintf("### %d. %s\n\n", i+1, member.CN))
-		content.WriteString(fmt.Sprintf("**性别**: %s\n\n", member.Sex))
-		content.WriteString(fmt.Sprintf("**年级**: %s\n\n", member.Year))
-		content.WriteString(fmt.Sprintf("**方向**: %s\n\n", member.Direction))
+		if public["sex"] == models.VisibilityPublic {
+			content.WriteString(fmt.Sprintf("**性别**: %s\n\n", member.Sex))
+		}
+		if public["year"] == models.VisibilityPublic {
+			content.WriteString(fmt.Sprintf("**年级**: %s\n\n", member.Year))
+		}
+		if public["direction"] == models.VisibilityPublic {
+			content.WriteString(fmt.Sprintf("**方向**: %s\n\n", member.Direction))
+		}
 		content.WriteString(fmt.Sprintf("**职位**: %s\n\n", member.Position))
-		content.WriteString(fmt.Sprintf("**状态**: %s\n\n", member.Status))
+		content.WriteString(fmt.Sprintf("**状态**: %s\n\n", models.MemberStatusLabel(member.Status)))
 
-		if member.Remark != "" {
+		if member.Remark != "" && public["remark"] == models.VisibilityPublic {
 			content.WriteString(fmt.Sprintf("**备注**: %s\n\n", member.Remark))
 		}
 
@@ -4,6 +4,8 @@ import router from './router/index.js'
 import ArcoVue from '@arco-design/web-vue'
 import '@arco-design/web-vue/dist/arco.css'
 import './style.css'
+import axios from 'axios'
+import { installAuthInterceptors } from './utils/auth'
 
 // 初始化主题设置
 const initTheme = () => {
@@ -21,4 +23,7 @@ const initTheme = () => {
 // 在应用启动前初始化主题
 initTheme()
 
+// 请求自动携带 token，访问 token 过期时自动刷新
+installAuthInterceptors(axios)
+
 createApp(App).use(router).use(ArcoVue).mount('#app')
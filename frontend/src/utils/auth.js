@@ -1,9 +1,25 @@
+import axios from 'axios'
+import { apiUrl } from './apiUrl'
+
 // 认证相关工具函数
 export const auth = {
   // 获取token
   getToken() {
     return localStorage.getItem('token')
   },
+
+  // 获取刷新令牌
+  getRefreshToken() {
+    return localStorage.getItem('refreshToken')
+  },
+
+  // 保存登录/刷新接口返回的访问 token 与刷新令牌
+  setSession({ token, refresh_token }) {
+    localStorage.setItem('token', token)
+    if (refresh_token) {
+      localStorage.setItem('refreshToken', refresh_token)
+    }
+  },
   
   // 获取用户信息
   getUserInfo() {
@@ -27,12 +43,28 @@ export const auth = {
     return localStorage.getItem('userType')
   },
   
-  // 登出
-  logout() {
+  // 清除本地登录状态
+  clear() {
     localStorage.removeItem('token')
+    localStorage.removeItem('refreshToken')
     localStorage.removeItem('userInfo')
     localStorage.removeItem('userType')
   },
+
+  // 登出：先注销服务端会话，失败（如 token 已过期）也照常清除本地状态
+  async logout() {
+    const token = this.getToken()
+    if (token) {
+      try {
+        await axios.post(apiUrl('/api/logout'), null, {
+          headers: { Authorization: `Bearer ${token}` }
+        })
+      } catch (e) {
+        // 忽略：会话可能已失效
+      }
+    }
+    this.clear()
+  },
   
   // 设置axios默认header
   setAuthHeader(axios) {
@@ -105,3 +137,61 @@ export const requireAuth = (to, from, next) => {
   
   next()
 }
+
+// 刷新访问 token；并发的 401 共用同一次刷新
+let refreshing = null
+const refreshSession = () => {
+  if (!refreshing) {
+    const refreshToken = auth.getRefreshToken()
+    refreshing = (refreshToken
+      ? axios.post(apiUrl('/api/refresh'), { refresh_token: refreshToken }, { _skipAuthRefresh: true })
+      : Promise.reject(new Error('no refresh token'))
+    )
+      .then((response) => {
+        auth.setSession(response.data)
+        return response.data.token
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// 安装 axios 拦截器：请求自动携带访问 token；访问 token 过期（401）时用刷新令牌换新后重试一次，刷新失败则清除登录状态
+export const installAuthInterceptors = (instance = axios) => {
+  instance.interceptors.request.use((config) => {
+    const token = auth.getToken()
+    if (token && !config.headers?.Authorization) {
+      config.headers = config.headers || {}
+      config.headers.Authorization = `Bearer ${token}`
+    }
+    return config
+  })
+
+  instance.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+      const config = error.config
+      if (
+        error.response?.status !== 401 ||
+        !config ||
+        config._skipAuthRefresh ||
+        config._retried ||
+        !auth.getRefreshToken()
+      ) {
+        return Promise.reject(error)
+      }
+      config._retried = true
+      try {
+        const token = await refreshSession()
+        config.headers = config.headers || {}
+        config.headers.Authorization = `Bearer ${token}`
+        return instance(config)
+      } catch (e) {
+        auth.clear()
+        return Promise.reject(error)
+      }
+    }
+  )
+}